@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// eventKind identifies the shape of an incoming Lambda event
+type eventKind string
+
+const (
+	eventKindAPIGatewayV1 eventKind = "apigateway-v1" // API Gateway REST API (payload format 1.0)
+	eventKindAPIGatewayV2 eventKind = "apigateway-v2" // API Gateway HTTP API (payload format 2.0)
+	eventKindFunctionURL  eventKind = "function-url"  // Lambda Function URL
+	eventKindALB          eventKind = "alb"           // Application Load Balancer target group
+)
+
+// eventProbe holds just enough of an event to tell the supported payload formats apart
+type eventProbe struct {
+	Version        string `json:"version"`
+	RequestContext struct {
+		ELB        *json.RawMessage `json:"elb"`
+		DomainName string           `json:"domainName"`
+	} `json:"requestContext"`
+}
+
+// detectEventKind determines which integration sent the event
+func detectEventKind(payload json.RawMessage) (eventKind, error) {
+	var probe eventProbe
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return "", fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	switch {
+	case probe.RequestContext.ELB != nil:
+		return eventKindALB, nil
+	case probe.Version == "2.0" && strings.Contains(probe.RequestContext.DomainName, ".lambda-url."):
+		return eventKindFunctionURL, nil
+	case probe.Version == "2.0":
+		return eventKindAPIGatewayV2, nil
+	default:
+		return eventKindAPIGatewayV1, nil
+	}
+}
+
+// decodeBody returns the request body as text, decoding it if the integration base64-encoded it
+func decodeBody(body string, isBase64Encoded bool) (string, error) {
+	if !isBase64Encoded {
+		return body, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64 request body: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// splitQueryValues builds multi-value query parameters from the comma-joined values used by payload format 2.0
+func splitQueryValues(params map[string]string) map[string][]string {
+	multiValue := make(map[string][]string, len(params))
+	for name, value := range params {
+		multiValue[name] = strings.Split(value, ",")
+	}
+	return multiValue
+}
+
+// multiValueHeaders builds multi-value headers from single-value headers
+func multiValueHeaders(headers map[string]string) map[string][]string {
+	multiValue := make(map[string][]string, len(headers))
+	for name, value := range headers {
+		multiValue[name] = []string{value}
+	}
+	return multiValue
+}
+
+// v2Headers copies payload format 2.0 headers, restoring cookies that are delivered separately
+func v2Headers(headers map[string]string, cookies []string) map[string]string {
+	copied := make(map[string]string, len(headers)+1)
+	for name, value := range headers {
+		copied[name] = value
+	}
+	if len(cookies) > 0 {
+		copied["cookie"] = strings.Join(cookies, "; ")
+	}
+	return copied
+}
+
+// unescapePath decodes a raw request path once, so handlers see the same decoded path as from
+// REST API events and the standalone server
+func unescapePath(rawPath string) (string, error) {
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode request path: %w", err)
+	}
+	return path, nil
+}
+
+// proxyRequestFromV2 normalizes an API Gateway HTTP API event into the request shape used by the handlers
+func proxyRequestFromV2(event events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyRequest, error) {
+	body, err := decodeBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	// Named stages are part of the raw path, unlike in REST API events
+	path, err := unescapePath(event.RawPath)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+	if stage := event.RequestContext.Stage; stage != "" && stage != "$default" && strings.HasPrefix(path, "/"+stage+"/") {
+		path = strings.TrimPrefix(path, "/"+stage)
+	}
+
+	headers := v2Headers(event.Headers, event.Cookies)
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:                      event.RequestContext.HTTP.Method,
+		Path:                            path,
+		Headers:                         headers,
+		MultiValueHeaders:               multiValueHeaders(headers),
+		QueryStringParameters:           event.QueryStringParameters,
+		MultiValueQueryStringParameters: splitQueryValues(event.QueryStringParameters),
+		PathParameters:                  event.PathParameters,
+		StageVariables:                  event.StageVariables,
+		Body:                            body,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:  event.RequestContext.AccountID,
+			RequestID:  event.RequestContext.RequestID,
+			Stage:      event.RequestContext.Stage,
+			DomainName: event.RequestContext.DomainName,
+			APIID:      event.RequestContext.APIID,
+			HTTPMethod: event.RequestContext.HTTP.Method,
+			Path:       event.RequestContext.HTTP.Path,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  event.RequestContext.HTTP.SourceIP,
+				UserAgent: event.RequestContext.HTTP.UserAgent,
+			},
+		},
+	}
+
+	if authorizer := event.RequestContext.Authorizer; authorizer != nil {
+		if iam := authorizer.IAM; iam != nil {
+			request.RequestContext.Identity.UserArn = iam.UserARN
+			request.RequestContext.Identity.User = iam.UserID
+			request.RequestContext.Identity.Caller = iam.CallerID
+			request.RequestContext.Identity.AccessKey = iam.AccessKey
+			request.RequestContext.Identity.AccountID = iam.AccountID
+		}
+
+		// Expose authorizer context the way REST API authorizers do
+		request.RequestContext.Authorizer = map[string]interface{}{}
+		for key, value := range authorizer.Lambda {
+			request.RequestContext.Authorizer[key] = value
+		}
+		if jwt := authorizer.JWT; jwt != nil {
+			claims := make(map[string]interface{}, len(jwt.Claims))
+			for key, value := range jwt.Claims {
+				claims[key] = value
+			}
+			request.RequestContext.Authorizer["claims"] = claims
+		}
+	}
+
+	return request, nil
+}
+
+// proxyRequestFromFunctionURL normalizes a Lambda Function URL event into the request shape used by the handlers
+func proxyRequestFromFunctionURL(event events.LambdaFunctionURLRequest) (events.APIGatewayProxyRequest, error) {
+	body, err := decodeBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	path, err := unescapePath(event.RawPath)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	headers := v2Headers(event.Headers, event.Cookies)
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:                      event.RequestContext.HTTP.Method,
+		Path:                            path,
+		Headers:                         headers,
+		MultiValueHeaders:               multiValueHeaders(headers),
+		QueryStringParameters:           event.QueryStringParameters,
+		MultiValueQueryStringParameters: splitQueryValues(event.QueryStringParameters),
+		Body:                            body,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			AccountID:  event.RequestContext.AccountID,
+			RequestID:  event.RequestContext.RequestID,
+			DomainName: event.RequestContext.DomainName,
+			APIID:      event.RequestContext.APIID,
+			HTTPMethod: event.RequestContext.HTTP.Method,
+			Path:       event.RequestContext.HTTP.Path,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  event.RequestContext.HTTP.SourceIP,
+				UserAgent: event.RequestContext.HTTP.UserAgent,
+			},
+		},
+	}
+
+	if authorizer := event.RequestContext.Authorizer; authorizer != nil && authorizer.IAM != nil {
+		request.RequestContext.Identity.UserArn = authorizer.IAM.UserARN
+		request.RequestContext.Identity.User = authorizer.IAM.UserID
+		request.RequestContext.Identity.Caller = authorizer.IAM.CallerID
+		request.RequestContext.Identity.AccessKey = authorizer.IAM.AccessKey
+		request.RequestContext.Identity.AccountID = authorizer.IAM.AccountID
+	}
+
+	return request, nil
+}
+
+// unescapeALBQuery decodes query parameters, which ALB passes through still percent-encoded
+func unescapeALBQuery(values []string) []string {
+	unescaped := make([]string, len(values))
+	for i, value := range values {
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		unescaped[i] = value
+	}
+	return unescaped
+}
+
+// proxyRequestFromALB normalizes an ALB target group event into the request shape used by the handlers
+func proxyRequestFromALB(event events.ALBTargetGroupRequest) (events.APIGatewayProxyRequest, error) {
+	body, err := decodeBody(event.Body, event.IsBase64Encoded)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	// ALB passes the path through still percent-encoded, like the query
+	path, err := unescapePath(event.Path)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:                      event.HTTPMethod,
+		Path:                            path,
+		Headers:                         make(map[string]string),
+		MultiValueHeaders:               make(map[string][]string),
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: make(map[string][]string),
+		Body:                            body,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			HTTPMethod: event.HTTPMethod,
+			Path:       event.Path,
+		},
+	}
+
+	// The target group delivers either single or multi-value fields, depending on its settings
+	if len(event.MultiValueHeaders) > 0 {
+		for name, values := range event.MultiValueHeaders {
+			request.Headers[name] = strings.Join(values, ",")
+			request.MultiValueHeaders[name] = values
+		}
+	} else {
+		request.Headers = event.Headers
+		request.MultiValueHeaders = multiValueHeaders(event.Headers)
+	}
+
+	if len(event.MultiValueQueryStringParameters) > 0 {
+		for name, values := range event.MultiValueQueryStringParameters {
+			values = unescapeALBQuery(values)
+			request.QueryStringParameters[name] = values[len(values)-1]
+			request.MultiValueQueryStringParameters[name] = values
+		}
+	} else {
+		for name, value := range event.QueryStringParameters {
+			values := unescapeALBQuery([]string{value})
+			request.QueryStringParameters[name] = values[0]
+			request.MultiValueQueryStringParameters[name] = values
+		}
+	}
+
+	if forwardedFor := getHeader(request, "X-Forwarded-For"); forwardedFor != "" {
+		request.RequestContext.Identity.SourceIP = strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+	}
+
+	return request, nil
+}
+
+// flattenHeaders merges single and multi-value response headers into single values
+func flattenHeaders(response events.APIGatewayProxyResponse) map[string]string {
+	headers := make(map[string]string, len(response.Headers)+len(response.MultiValueHeaders))
+	for name, value := range response.Headers {
+		headers[name] = value
+	}
+	for name, values := range response.MultiValueHeaders {
+		headers[name] = strings.Join(values, ",")
+	}
+	return headers
+}
+
+// albResponse renders a response for an ALB target group, matching the request's multi-value setting
+func albResponse(response events.APIGatewayProxyResponse, multiValue bool) events.ALBTargetGroupResponse {
+	alb := events.ALBTargetGroupResponse{
+		StatusCode:        response.StatusCode,
+		StatusDescription: fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		Body:              response.Body,
+		IsBase64Encoded:   response.IsBase64Encoded,
+	}
+
+	headers := flattenHeaders(response)
+	if multiValue {
+		alb.MultiValueHeaders = multiValueHeaders(headers)
+	} else {
+		alb.Headers = headers
+	}
+	return alb
+}
+
+// normalizedEvent is an incoming event converted to the internal request shape,
+// along with what is needed to render the matching response type
+type normalizedEvent struct {
+	kind       eventKind
+	request    events.APIGatewayProxyRequest
+	multiValue bool // ALB target group with multi-value headers enabled
+}
+
+// normalizeEvent detects the event format and converts it to the internal request shape
+func normalizeEvent(payload json.RawMessage) (normalizedEvent, error) {
+	kind, err := detectEventKind(payload)
+	if err != nil {
+		return normalizedEvent{}, err
+	}
+	event := normalizedEvent{kind: kind}
+
+	switch kind {
+	case eventKindAPIGatewayV2:
+		var v2 events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &v2); err != nil {
+			return event, fmt.Errorf("failed to parse HTTP API event: %w", err)
+		}
+		event.request, err = proxyRequestFromV2(v2)
+	case eventKindFunctionURL:
+		var functionURL events.LambdaFunctionURLRequest
+		if err := json.Unmarshal(payload, &functionURL); err != nil {
+			return event, fmt.Errorf("failed to parse Function URL event: %w", err)
+		}
+		event.request, err = proxyRequestFromFunctionURL(functionURL)
+	case eventKindALB:
+		var alb events.ALBTargetGroupRequest
+		if err := json.Unmarshal(payload, &alb); err != nil {
+			return event, fmt.Errorf("failed to parse ALB event: %w", err)
+		}
+		event.multiValue = len(alb.MultiValueHeaders) > 0
+		event.request, err = proxyRequestFromALB(alb)
+	default:
+		if err := json.Unmarshal(payload, &event.request); err != nil {
+			return event, fmt.Errorf("failed to parse API Gateway event: %w", err)
+		}
+		event.request.Body, err = decodeBody(event.request.Body, event.request.IsBase64Encoded)
+		event.request.IsBase64Encoded = false
+	}
+
+	return event, err
+}
+
+// renderResponse converts a handler response into the response type expected by the event's integration
+func renderResponse(event normalizedEvent, response events.APIGatewayProxyResponse) interface{} {
+	switch event.kind {
+	case eventKindAPIGatewayV2:
+		return events.APIGatewayV2HTTPResponse{
+			StatusCode:      response.StatusCode,
+			Headers:         flattenHeaders(response),
+			Body:            response.Body,
+			IsBase64Encoded: response.IsBase64Encoded,
+		}
+	case eventKindFunctionURL:
+		return events.LambdaFunctionURLResponse{
+			StatusCode:      response.StatusCode,
+			Headers:         flattenHeaders(response),
+			Body:            response.Body,
+			IsBase64Encoded: response.IsBase64Encoded,
+		}
+	case eventKindALB:
+		return albResponse(response, event.multiValue)
+	default:
+		return response
+	}
+}
+
+// handleLambdaEvent is the Lambda entry point. It detects whether the event came from an API Gateway
+// REST API, HTTP API, Function URL or ALB, routes it through handleRequest and renders the matching response.
+func handleLambdaEvent(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	defer flushTraces(ctx)
+
+	event, err := normalizeEvent(payload)
+	if err != nil {
+		if event.kind == "" {
+			return nil, err
+		}
+		slog.WarnContext(ctx, "Failed to read event", "kind", event.kind, "error", err)
+		response, _ := respondLambdaWithStatus(http.StatusBadRequest, false, err.Error())
+		return renderResponse(event, response), nil
+	}
+
+	response, err := handleRequest(ctx, event.request)
+	if err != nil {
+		return nil, err
+	}
+	return renderResponse(event, response), nil
+}
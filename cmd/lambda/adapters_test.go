@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	restAPIHealthEvent = `{
+		"resource": "/health",
+		"path": "/health",
+		"httpMethod": "GET",
+		"headers": {"Accept": "application/json"},
+		"requestContext": {"requestId": "rest-1", "identity": {"sourceIp": "198.51.100.1"}}
+	}`
+	httpAPIHealthEvent = `{
+		"version": "2.0",
+		"routeKey": "GET /health",
+		"rawPath": "/health",
+		"rawQueryString": "",
+		"headers": {"accept": "application/json"},
+		"requestContext": {
+			"requestId": "http-1",
+			"stage": "$default",
+			"domainName": "abc123.execute-api.us-east-1.amazonaws.com",
+			"http": {"method": "GET", "path": "/health", "sourceIp": "198.51.100.1"}
+		},
+		"isBase64Encoded": false
+	}`
+	functionURLHealthEvent = `{
+		"version": "2.0",
+		"routeKey": "$default",
+		"rawPath": "/health",
+		"headers": {"accept": "application/json"},
+		"requestContext": {
+			"requestId": "url-1",
+			"domainName": "abc123.lambda-url.us-east-1.on.aws",
+			"http": {"method": "GET", "path": "/health", "sourceIp": "198.51.100.1"}
+		},
+		"isBase64Encoded": false
+	}`
+	albHealthEvent = `{
+		"requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/wizard/abc"}},
+		"httpMethod": "GET",
+		"path": "/health",
+		"headers": {"accept": "application/json", "x-forwarded-for": "198.51.100.1, 10.0.0.1"},
+		"body": "",
+		"isBase64Encoded": false
+	}`
+	albMultiValueHealthEvent = `{
+		"requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/wizard/abc"}},
+		"httpMethod": "GET",
+		"path": "/health",
+		"multiValueHeaders": {"accept": ["application/json"]},
+		"multiValueQueryStringParameters": {"format": ["yaml", "json%20lines"]},
+		"body": "",
+		"isBase64Encoded": false
+	}`
+)
+
+func TestDetectEventKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected eventKind
+	}{
+		{"REST API", restAPIHealthEvent, eventKindAPIGatewayV1},
+		{"HTTP API", httpAPIHealthEvent, eventKindAPIGatewayV2},
+		{"Function URL", functionURLHealthEvent, eventKindFunctionURL},
+		{"ALB", albHealthEvent, eventKindALB},
+		{"ALB multi-value", albMultiValueHealthEvent, eventKindALB},
+	}
+
+	for _, tt := range tests {
+		kind, err := detectEventKind(json.RawMessage(tt.payload))
+		if err != nil || kind != tt.expected {
+			t.Errorf("detectEventKind(%s) = %v, %v, want %v", tt.name, kind, err, tt.expected)
+		}
+	}
+
+	if _, err := detectEventKind(json.RawMessage(`not json`)); err == nil {
+		t.Errorf("detectEventKind() should fail for invalid JSON")
+	}
+}
+
+func TestHandleLambdaEventResponseTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		check   func(t *testing.T, response interface{})
+	}{
+		{"REST API", restAPIHealthEvent, func(t *testing.T, response interface{}) {
+			r, ok := response.(events.APIGatewayProxyResponse)
+			if !ok || r.StatusCode != http.StatusOK {
+				t.Errorf("response = %#v, want APIGatewayProxyResponse with status 200", response)
+			}
+		}},
+		{"HTTP API", httpAPIHealthEvent, func(t *testing.T, response interface{}) {
+			r, ok := response.(events.APIGatewayV2HTTPResponse)
+			if !ok || r.StatusCode != http.StatusOK || r.Headers["Content-Type"] != "application/json" {
+				t.Errorf("response = %#v, want APIGatewayV2HTTPResponse with status 200", response)
+			}
+		}},
+		{"Function URL", functionURLHealthEvent, func(t *testing.T, response interface{}) {
+			r, ok := response.(events.LambdaFunctionURLResponse)
+			if !ok || r.StatusCode != http.StatusOK || r.Headers["Content-Type"] != "application/json" {
+				t.Errorf("response = %#v, want LambdaFunctionURLResponse with status 200", response)
+			}
+		}},
+		{"ALB", albHealthEvent, func(t *testing.T, response interface{}) {
+			r, ok := response.(events.ALBTargetGroupResponse)
+			if !ok || r.StatusCode != http.StatusOK || r.StatusDescription != "200 OK" || r.Headers["Content-Type"] != "application/json" || r.MultiValueHeaders != nil {
+				t.Errorf("response = %#v, want ALBTargetGroupResponse with single-value headers", response)
+			}
+		}},
+		{"ALB multi-value", albMultiValueHealthEvent, func(t *testing.T, response interface{}) {
+			r, ok := response.(events.ALBTargetGroupResponse)
+			if !ok || r.StatusCode != http.StatusOK || r.Headers != nil || r.MultiValueHeaders["Content-Type"][0] != "application/json" {
+				t.Errorf("response = %#v, want ALBTargetGroupResponse with multi-value headers", response)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := handleLambdaEvent(context.Background(), json.RawMessage(tt.payload))
+			if err != nil {
+				t.Fatalf("handleLambdaEvent() error = %v", err)
+			}
+			tt.check(t, response)
+		})
+	}
+}
+
+func TestHandleLambdaEventBase64Body(t *testing.T) {
+	// {"appID":"","userGroups":[]}
+	payload := `{
+		"version": "2.0",
+		"rawPath": "/api/create-project",
+		"headers": {"content-type": "application/json"},
+		"requestContext": {"stage": "$default", "domainName": "abc123.execute-api.us-east-1.amazonaws.com", "http": {"method": "POST", "path": "/api/create-project"}},
+		"body": "eyJhcHBJRCI6IiIsInVzZXJHcm91cHMiOltdfQ==",
+		"isBase64Encoded": true
+	}`
+
+	response, err := handleLambdaEvent(context.Background(), json.RawMessage(payload))
+	if err != nil {
+		t.Fatalf("handleLambdaEvent() error = %v", err)
+	}
+
+	r := response.(events.APIGatewayV2HTTPResponse)
+	var resp Response
+	if err := json.Unmarshal([]byte(r.Body), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if r.StatusCode != http.StatusBadRequest || resp.Code != codeValidationFailed {
+		t.Errorf("response = %d %+v, want validation failure for decoded body", r.StatusCode, resp)
+	}
+
+	// Undecodable bodies are rejected in the integration's response format
+	payload = `{
+		"requestContext": {"elb": {"targetGroupArn": "arn"}},
+		"httpMethod": "POST",
+		"path": "/api/create-project",
+		"body": "not base64!",
+		"isBase64Encoded": true
+	}`
+	response, err = handleLambdaEvent(context.Background(), json.RawMessage(payload))
+	if err != nil {
+		t.Fatalf("handleLambdaEvent() error = %v", err)
+	}
+	if r, ok := response.(events.ALBTargetGroupResponse); !ok || r.StatusCode != http.StatusBadRequest {
+		t.Errorf("response = %#v, want ALB 400", response)
+	}
+}
+
+func TestProxyRequestFromV2(t *testing.T) {
+	event := events.APIGatewayV2HTTPRequest{
+		RawPath:               "/prod/api/projects/my-project",
+		Cookies:               []string{"a=1", "b=2"},
+		Headers:               map[string]string{"authorization": "Bearer token"},
+		QueryStringParameters: map[string]string{"format": "yaml,json"},
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			RequestID: "req-1",
+			Stage:     "prod",
+			HTTP:      events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "GET", SourceIP: "198.51.100.1"},
+			Authorizer: &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+				JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{Claims: map[string]string{"email": "user@example.com"}},
+				IAM: &events.APIGatewayV2HTTPRequestContextAuthorizerIAMDescription{UserARN: "arn:aws:iam::123456789012:user/alice"},
+			},
+		},
+	}
+
+	request, err := proxyRequestFromV2(event)
+	if err != nil {
+		t.Fatalf("proxyRequestFromV2() error = %v", err)
+	}
+
+	if request.HTTPMethod != "GET" || request.Path != "/api/projects/my-project" || request.RequestContext.RequestID != "req-1" {
+		t.Errorf("proxyRequestFromV2() = %+v", request)
+	}
+	if getHeader(request, "Cookie") != "a=1; b=2" || getHeader(request, "Authorization") != "Bearer token" {
+		t.Errorf("proxyRequestFromV2() headers = %v", request.Headers)
+	}
+	if len(request.MultiValueQueryStringParameters["format"]) != 2 {
+		t.Errorf("proxyRequestFromV2() query = %v", request.MultiValueQueryStringParameters)
+	}
+	if request.RequestContext.Identity.UserArn != "arn:aws:iam::123456789012:user/alice" || request.RequestContext.Identity.SourceIP != "198.51.100.1" {
+		t.Errorf("proxyRequestFromV2() identity = %+v", request.RequestContext.Identity)
+	}
+	claims, _ := request.RequestContext.Authorizer["claims"].(map[string]interface{})
+	if claims["email"] != "user@example.com" {
+		t.Errorf("proxyRequestFromV2() authorizer = %v", request.RequestContext.Authorizer)
+	}
+
+	// Paths that merely start with the stage name are left alone
+	event.RawPath = "/production/health"
+	request, _ = proxyRequestFromV2(event)
+	if request.Path != "/production/health" {
+		t.Errorf("proxyRequestFromV2() path = %q, want %q", request.Path, "/production/health")
+	}
+
+	// The raw path is decoded exactly once
+	event.RawPath = "/prod/api/projects/my-project/members/user%40example.com"
+	request, _ = proxyRequestFromV2(event)
+	if request.Path != "/api/projects/my-project/members/user@example.com" {
+		t.Errorf("proxyRequestFromV2() path = %q, want the email decoded", request.Path)
+	}
+	event.RawPath = "/api/projects/my-project/members/100%2525"
+	request, _ = proxyRequestFromV2(event)
+	if request.Path != "/api/projects/my-project/members/100%25" {
+		t.Errorf("proxyRequestFromV2() path = %q, want %q", request.Path, "/api/projects/my-project/members/100%25")
+	}
+	event.RawPath = "/api/projects/my-project/members/100%zz"
+	if _, err := proxyRequestFromV2(event); err == nil {
+		t.Error("proxyRequestFromV2() with an invalid escape succeeded")
+	}
+}
+
+func TestProxyRequestFromALB(t *testing.T) {
+	var event events.ALBTargetGroupRequest
+	if err := json.Unmarshal([]byte(albMultiValueHealthEvent), &event); err != nil {
+		t.Fatalf("Failed to parse event: %v", err)
+	}
+
+	request, err := proxyRequestFromALB(event)
+	if err != nil {
+		t.Fatalf("proxyRequestFromALB() error = %v", err)
+	}
+	if request.QueryStringParameters["format"] != "json lines" || request.MultiValueQueryStringParameters["format"][0] != "yaml" {
+		t.Errorf("proxyRequestFromALB() query = %v, %v", request.QueryStringParameters, request.MultiValueQueryStringParameters)
+	}
+
+	var singleValueEvent events.ALBTargetGroupRequest
+	if err := json.Unmarshal([]byte(albHealthEvent), &singleValueEvent); err != nil {
+		t.Fatalf("Failed to parse event: %v", err)
+	}
+	request, _ = proxyRequestFromALB(singleValueEvent)
+	if request.RequestContext.Identity.SourceIP != "198.51.100.1" {
+		t.Errorf("proxyRequestFromALB() source IP = %q", request.RequestContext.Identity.SourceIP)
+	}
+
+	// ALB passes the path through still encoded
+	singleValueEvent.Path = "/api/projects/my-project/members/user%40example.com"
+	request, _ = proxyRequestFromALB(singleValueEvent)
+	if request.Path != "/api/projects/my-project/members/user@example.com" {
+		t.Errorf("proxyRequestFromALB() path = %q, want the email decoded", request.Path)
+	}
+}
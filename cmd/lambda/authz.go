@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/goccy/go-yaml"
+)
+
+// codeForbidden is returned when the authorization policy denies a request
+const codeForbidden = "forbidden"
+
+// authzPolicy is the authorization policy loaded at startup, or nil if every caller may do anything
+var authzPolicy *AuthzPolicy
+
+// Caller is the identity that sent a request, as established by API Gateway or an authorizer
+type Caller struct {
+	ID     string   // Authorizer subject, IAM ARN or Cognito identity ID; empty for anonymous callers
+	Email  string   // Email claim, if any
+	Groups []string // Groups from the cognito:groups or groups claim
+}
+
+// Anonymous reports whether the request carried no identity at all
+func (c Caller) Anonymous() bool {
+	return c.ID == "" && c.Email == ""
+}
+
+// String names the caller in messages
+func (c Caller) String() string {
+	if c.Email != "" {
+		return c.Email
+	}
+	if c.ID != "" {
+		return c.ID
+	}
+	return "anonymous"
+}
+
+// callerKey is the context key for the caller of a request
+type callerKey struct{}
+
+// withCaller returns a context carrying the caller of the request
+func withCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// callerFromContext returns the caller stored in the context, if any
+func callerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// claimString reads a string claim
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimList reads a claim holding several values. JSON arrays are kept as they are; strings, which is how
+// API Gateway passes array claims, may be comma or space separated and wrapped in brackets, e.g. "[admins ops]".
+func claimList(value interface{}) []string {
+	var values []string
+	switch v := value.(type) {
+	case []string:
+		values = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	case string:
+		values = strings.FieldsFunc(strings.Trim(v, "[]"), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	}
+
+	var cleaned []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			cleaned = append(cleaned, value)
+		}
+	}
+	return cleaned
+}
+
+// callerFromRequest extracts the caller from Cognito or JWT authorizer claims, Lambda authorizer
+// context or the IAM identity of a request, in that order
+func callerFromRequest(request events.APIGatewayProxyRequest) Caller {
+	authorizer := request.RequestContext.Authorizer
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		caller := Caller{ID: claimString(claims, "sub"), Email: claimString(claims, "email")}
+		if caller.ID == "" {
+			caller.ID = claimString(claims, "cognito:username")
+		}
+		for _, claim := range []string{"cognito:groups", "groups"} {
+			caller.Groups = append(caller.Groups, claimList(claims[claim])...)
+		}
+		return caller
+	}
+
+	// Lambda authorizers return a principal ID and a flat context
+	if principalID, ok := authorizer["principalId"].(string); ok && principalID != "" {
+		email, _ := authorizer["email"].(string)
+		return Caller{ID: principalID, Email: email, Groups: claimList(authorizer["groups"])}
+	}
+
+	identity := request.RequestContext.Identity
+	switch {
+	case identity.UserArn != "":
+		return Caller{ID: identity.UserArn}
+	case identity.CognitoIdentityID != "":
+		return Caller{ID: identity.CognitoIdentityID}
+	}
+	return Caller{}
+}
+
+// AuthzRule grants the callers it applies to the right to manage some projects and grant some roles
+type AuthzRule struct {
+	Name             string   `json:"name"`                       // Name of the rule, for readers of the policy
+	Groups           []string `json:"groups,omitempty"`           // Caller groups the rule applies to
+	Principals       []string `json:"principals,omitempty"`       // Caller IDs, ARNs or emails the rule applies to; * matches anything
+	ProjectPrefixes  []string `json:"projectPrefixes,omitempty"`  // Prefixes of the projects callers may manage; any project if empty
+	GrantableRoles   []string `json:"grantableRoles,omitempty"`   // Roles callers may grant and revoke; any role if empty
+	RequireSelfOwner bool     `json:"requireSelfOwner,omitempty"` // New projects must list the caller as a project-owner
+	LookupUsers      bool     `json:"lookupUsers,omitempty"`      // Callers may check which users exist through /api/users/lookup
+}
+
+// AuthzPolicy decides what callers may do. A request is allowed if any rule that applies to its caller allows all of it.
+type AuthzPolicy struct {
+	Rules []AuthzRule `json:"rules"`
+}
+
+// authzRequest describes what a request would do, for the policy to decide on
+type authzRequest struct {
+	Project string   // Project the request manages; empty for requests not scoped to a project
+	Roles   []string // Roles the request grants or revokes
+	Owners  []string // Identifiers the request makes project-owner of a new project
+	Creates bool     // Whether the request creates the project
+	Lookup  bool     // Whether the request looks up users outside of managing a project
+}
+
+// forbiddenError is returned when the policy denies a request
+type forbiddenError struct {
+	message string
+}
+
+// Error returns the human-readable reason the request was denied
+func (e *forbiddenError) Error() string {
+	return e.message
+}
+
+// wildcardMatch reports whether value matches a case-insensitive pattern in which * matches any characters
+func wildcardMatch(pattern, value string) bool {
+	expr := "(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expr, value)
+	return matched
+}
+
+// appliesTo reports whether the rule applies to the caller
+func (r AuthzRule) appliesTo(caller Caller) bool {
+	for _, group := range r.Groups {
+		for _, callerGroup := range caller.Groups {
+			if group == callerGroup {
+				return true
+			}
+		}
+	}
+	for _, principal := range r.Principals {
+		if (caller.ID != "" && wildcardMatch(principal, caller.ID)) || (caller.Email != "" && wildcardMatch(principal, caller.Email)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns why the rule does not allow the request for the caller, or nil if it does
+func (r AuthzRule) allows(caller Caller, req authzRequest) error {
+	// Looking users up reveals who belongs to the Nobl9 organization, so it must be granted explicitly
+	if req.Lookup && !r.LookupUsers {
+		return &forbiddenError{fmt.Sprintf("Caller '%s' may not look up users", caller)}
+	}
+
+	if req.Project != "" && len(r.ProjectPrefixes) > 0 {
+		allowed := false
+		for _, prefix := range r.ProjectPrefixes {
+			if strings.HasPrefix(req.Project, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &forbiddenError{fmt.Sprintf("Caller '%s' may only manage projects starting with %s", caller, quoteList(r.ProjectPrefixes))}
+		}
+	}
+
+	if len(r.GrantableRoles) > 0 {
+		for _, role := range req.Roles {
+			if !containsString(r.GrantableRoles, role) {
+				return &forbiddenError{fmt.Sprintf("Caller '%s' may not grant or revoke role '%s'", caller, role)}
+			}
+		}
+	}
+
+	if req.Creates && r.RequireSelfOwner {
+		isOwner := false
+		for _, owner := range req.Owners {
+			if strings.EqualFold(owner, caller.Email) || owner == caller.ID {
+				isOwner = true
+				break
+			}
+		}
+		if !isOwner {
+			return &forbiddenError{fmt.Sprintf("Caller '%s' must include themselves as a project-owner of new projects", caller)}
+		}
+	}
+	return nil
+}
+
+// Authorize allows the request if any rule that applies to the caller allows all of it, and otherwise
+// returns a forbiddenError with the reason given by the first applicable rule
+func (p *AuthzPolicy) Authorize(caller Caller, req authzRequest) error {
+	if caller.Anonymous() {
+		return &forbiddenError{"Request has no caller identity"}
+	}
+
+	var denied error
+	for _, rule := range p.Rules {
+		if !rule.appliesTo(caller) {
+			continue
+		}
+		err := rule.allows(caller, req)
+		if err == nil {
+			return nil
+		}
+		if denied == nil {
+			denied = err
+		}
+	}
+	if denied == nil {
+		denied = &forbiddenError{fmt.Sprintf("Caller '%s' is not allowed to use the wizard", caller)}
+	}
+	return denied
+}
+
+// authorize checks the request against the policy for the caller in ctx. Every request is allowed
+// when no policy is configured; with a policy, contexts without a caller are denied.
+func authorize(ctx context.Context, req authzRequest) error {
+	if authzPolicy == nil {
+		return nil
+	}
+	caller, _ := callerFromContext(ctx)
+	if err := authzPolicy.Authorize(caller, req); err != nil {
+		slog.InfoContext(ctx, "Request denied by authorization policy", "reason", err.Error())
+		return err
+	}
+	return nil
+}
+
+// userGroupRoles lists the distinct roles granted by the user groups
+func userGroupRoles(userGroups []UserGroup) []string {
+	var roles []string
+	for _, group := range userGroups {
+		if !containsString(roles, group.Role) {
+			roles = append(roles, group.Role)
+		}
+	}
+	return roles
+}
+
+// userGroupOwners lists the users the groups make project-owner
+func userGroupOwners(userGroups []UserGroup) []string {
+	var owners []string
+	for _, group := range userGroups {
+		if group.Role == "project-owner" {
+			owners = append(owners, splitUserIdentifiers(group.UserIDs)...)
+		}
+	}
+	return owners
+}
+
+// authorizeCreateProject checks that the caller may create the project with the requested roles
+func authorizeCreateProject(ctx context.Context, req CreateProjectRequest) error {
+	return authorize(ctx, authzRequest{
+		Project: req.AppID,
+		Roles:   userGroupRoles(req.UserGroups),
+		Owners:  userGroupOwners(req.UserGroups),
+		Creates: true,
+	})
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteList formats values as a quoted, comma separated list
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + value + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// parseAuthzPolicy reads an authorization policy from YAML or JSON and checks its rules
+func parseAuthzPolicy(data []byte) (*AuthzPolicy, error) {
+	var policy AuthzPolicy
+	if err := yaml.UnmarshalWithOptions(data, &policy, yaml.DisallowUnknownField()); err != nil {
+		return nil, fmt.Errorf("invalid authorization policy: %w", err)
+	}
+
+	for i, rule := range policy.Rules {
+		if len(rule.Groups) == 0 && len(rule.Principals) == 0 {
+			return nil, fmt.Errorf("invalid authorization policy: rules[%d] must list groups or principals", i)
+		}
+		for _, role := range rule.GrantableRoles {
+			if !validRoles[role] {
+				return nil, fmt.Errorf("invalid authorization policy: rules[%d] grants unknown role '%s'", i, role)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// loadAuthzPolicy loads the policy from the Parameter Store parameter named by AUTHZ_POLICY_PARAM_NAME
+// or the file named by AUTHZ_POLICY_FILE. It returns nil if neither is set.
+func loadAuthzPolicy(ctx context.Context, ssmAPI ssmGetParameterAPI) (*AuthzPolicy, error) {
+	var data []byte
+	var source string
+
+	switch paramName, file := os.Getenv("AUTHZ_POLICY_PARAM_NAME"), os.Getenv("AUTHZ_POLICY_FILE"); {
+	case paramName != "":
+		source = paramName
+		output, err := ssmAPI.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(paramName),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get authorization policy parameter: %w", err)
+		}
+		data = []byte(aws.ToString(output.Parameter.Value))
+	case file != "":
+		source = file
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read authorization policy: %w", err)
+		}
+		data = contents
+	default:
+		return nil, nil
+	}
+
+	policy, err := parseAuthzPolicy(data)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Loaded authorization policy", "rules", len(policy.Rules), "source", source)
+	return policy, nil
+}
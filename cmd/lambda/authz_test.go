@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// testAuthzPolicy lets payments admins manage payments- projects without granting project-owner,
+// and the platform role manage anything as long as it owns what it creates, and look users up
+const testAuthzPolicy = `
+rules:
+  - name: payments
+    groups: [payments-admins]
+    projectPrefixes: [payments-]
+    grantableRoles: [project-editor, project-viewer]
+  - name: platform
+    principals: ["arn:aws:sts::123456789012:assumed-role/platform/*", "*@platform.example.com"]
+    requireSelfOwner: true
+    lookupUsers: true
+`
+
+// useAuthzPolicy makes the policy the package policy for the rest of the test
+func useAuthzPolicy(t *testing.T, data string) {
+	t.Helper()
+	policy, err := parseAuthzPolicy([]byte(data))
+	if err != nil {
+		t.Fatalf("parseAuthzPolicy() error = %v", err)
+	}
+	previous := authzPolicy
+	authzPolicy = policy
+	t.Cleanup(func() { authzPolicy = previous })
+}
+
+func TestCallerFromRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		context  events.APIGatewayProxyRequestContext
+		expected Caller
+	}{
+		{
+			name: "cognito claims",
+			context: events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"sub": "abc-123", "email": "alice@example.com", "cognito:groups": "payments-admins,ops"},
+			}},
+			expected: Caller{ID: "abc-123", Email: "alice@example.com", Groups: []string{"payments-admins", "ops"}},
+		},
+		{
+			name: "http api jwt claims",
+			context: events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"sub": "abc-123", "groups": "[payments-admins ops]"},
+			}},
+			expected: Caller{ID: "abc-123", Groups: []string{"payments-admins", "ops"}},
+		},
+		{
+			name: "json array claim",
+			context: events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+				"claims": map[string]interface{}{"cognito:username": "alice", "groups": []interface{}{"ops"}},
+			}},
+			expected: Caller{ID: "alice", Groups: []string{"ops"}},
+		},
+		{
+			name: "lambda authorizer",
+			context: events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+				"principalId": "user|alice", "email": "alice@example.com", "groups": "ops",
+			}},
+			expected: Caller{ID: "user|alice", Email: "alice@example.com", Groups: []string{"ops"}},
+		},
+		{
+			name: "iam",
+			context: events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{
+				UserArn: "arn:aws:sts::123456789012:assumed-role/platform/session", CognitoIdentityID: "us-east-1:abc",
+			}},
+			expected: Caller{ID: "arn:aws:sts::123456789012:assumed-role/platform/session"},
+		},
+		{
+			name: "cognito identity",
+			context: events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{
+				CognitoIdentityID: "us-east-1:abc",
+			}},
+			expected: Caller{ID: "us-east-1:abc"},
+		},
+		{
+			name:     "anonymous",
+			expected: Caller{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller := callerFromRequest(events.APIGatewayProxyRequest{RequestContext: tt.context})
+			if !reflect.DeepEqual(caller, tt.expected) {
+				t.Errorf("callerFromRequest() = %+v, want %+v", caller, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthzPolicyAuthorize(t *testing.T) {
+	policy, err := parseAuthzPolicy([]byte(testAuthzPolicy))
+	if err != nil {
+		t.Fatalf("parseAuthzPolicy() error = %v", err)
+	}
+
+	paymentsAdmin := Caller{ID: "abc-123", Email: "alice@example.com", Groups: []string{"payments-admins"}}
+	platformRole := Caller{ID: "arn:aws:sts::123456789012:assumed-role/platform/deploy"}
+	platformUser := Caller{ID: "def-456", Email: "Bob@Platform.example.com"}
+
+	tests := []struct {
+		name    string
+		caller  Caller
+		req     authzRequest
+		allowed bool
+		reason  string
+	}{
+		{"prefix and roles allowed", paymentsAdmin, authzRequest{Project: "payments-api", Roles: []string{"project-editor"}}, true, ""},
+		{"other prefix", paymentsAdmin, authzRequest{Project: "billing-api"}, false, "may only manage projects starting with 'payments-'"},
+		{"role not grantable", paymentsAdmin, authzRequest{Project: "payments-api", Roles: []string{"project-owner"}}, false, "may not grant or revoke role 'project-owner'"},
+		{"not scoped to a project", paymentsAdmin, authzRequest{}, true, ""},
+		{"unknown caller", Caller{ID: "nobody"}, authzRequest{}, false, "is not allowed to use the wizard"},
+		{"anonymous", Caller{}, authzRequest{}, false, "no caller identity"},
+		{"principal wildcard", platformRole, authzRequest{Project: "billing-api", Roles: []string{"project-owner"}}, true, ""},
+		{"self owner missing", platformUser, authzRequest{Project: "billing-api", Roles: []string{"project-owner"}, Owners: []string{"carol@example.com"}, Creates: true}, false, "must include themselves"},
+		{"self owner by email", platformUser, authzRequest{Project: "billing-api", Roles: []string{"project-owner"}, Owners: []string{"bob@platform.example.com"}, Creates: true}, true, ""},
+		{"self owner not needed for members", platformUser, authzRequest{Project: "billing-api", Roles: []string{"project-viewer"}}, true, ""},
+		{"user lookup not granted", paymentsAdmin, authzRequest{Lookup: true}, false, "may not look up users"},
+		{"user lookup granted", platformRole, authzRequest{Lookup: true}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Authorize(tt.caller, tt.req)
+			if (err == nil) != tt.allowed {
+				t.Fatalf("Authorize() error = %v, want allowed %v", err, tt.allowed)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("Authorize() error = %q, want it to contain %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestParseAuthzPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		reason string
+	}{
+		{"no callers", "rules:\n  - name: everyone\n", "must list groups or principals"},
+		{"unknown role", "rules:\n  - groups: [ops]\n    grantableRoles: [project-admin]\n", "unknown role 'project-admin'"},
+		{"unknown field", "rules:\n  - groups: [ops]\n    projectPrefix: [ops-]\n", "projectPrefix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAuthzPolicy([]byte(tt.policy))
+			if err == nil || !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("parseAuthzPolicy() error = %v, want it to contain %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestLoadAuthzPolicy(t *testing.T) {
+	t.Setenv("AUTHZ_POLICY_PARAM_NAME", "")
+	t.Setenv("AUTHZ_POLICY_FILE", "")
+
+	// No policy configured
+	policy, err := loadAuthzPolicy(context.Background(), fakeSSM{})
+	if err != nil || policy != nil {
+		t.Fatalf("loadAuthzPolicy() without settings = %v, %v", policy, err)
+	}
+
+	// From a file, as JSON
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"rules":[{"name":"ops","groups":["ops"]}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AUTHZ_POLICY_FILE", path)
+	policy, err = loadAuthzPolicy(context.Background(), fakeSSM{})
+	if err != nil || len(policy.Rules) != 1 || policy.Rules[0].Groups[0] != "ops" {
+		t.Fatalf("loadAuthzPolicy() from file = %+v, %v", policy, err)
+	}
+
+	// The parameter takes precedence over the file
+	t.Setenv("AUTHZ_POLICY_PARAM_NAME", "/nobl9-wizard/authz-policy")
+	policy, err = loadAuthzPolicy(context.Background(), fakeSSM{"/nobl9-wizard/authz-policy": testAuthzPolicy})
+	if err != nil || len(policy.Rules) != 2 {
+		t.Fatalf("loadAuthzPolicy() from parameter = %+v, %v", policy, err)
+	}
+
+	if _, err := loadAuthzPolicy(context.Background(), fakeSSM{}); err == nil {
+		t.Error("loadAuthzPolicy() with a missing parameter succeeded")
+	}
+}
+
+func TestHandleRequestAuthorization(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+	useAuthzPolicy(t, testAuthzPolicy)
+	captureMetrics(t)
+
+	paymentsAdmin := events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{
+		"claims": map[string]interface{}{"sub": "abc-123", "email": "alice@example.com", "cognito:groups": "payments-admins"},
+	}}
+
+	tests := []struct {
+		name    string
+		request events.APIGatewayProxyRequest
+		status  int
+	}{
+		{
+			name: "create project outside the allowed prefixes",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "POST",
+				Path:           "/api/create-project",
+				Body:           `{"appID":"billing-api","userGroups":[{"userIds":"bob@example.com","role":"project-editor"}]}`,
+				RequestContext: paymentsAdmin,
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "create project granting a forbidden role",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "POST",
+				Path:           "/api/create-project",
+				Body:           `{"appID":"payments-api","userGroups":[{"userIds":"bob@example.com","role":"project-owner"}]}`,
+				RequestContext: paymentsAdmin,
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "add members anonymously",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: "POST",
+				Path:       "/api/projects/payments-api/members",
+				Body:       `{"userGroups":[{"userIds":"bob@example.com","role":"project-viewer"}]}`,
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:    "get project outside the allowed prefixes",
+			request: events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/api/projects/billing-api", RequestContext: paymentsAdmin},
+			status:  http.StatusForbidden,
+		},
+		{
+			name: "user lookup without the lookupUsers permission",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				Path:                  "/api/users/lookup",
+				QueryStringParameters: map[string]string{"email": "bob@example.com"},
+				RequestContext:        paymentsAdmin,
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:    "remove member outside the allowed prefixes",
+			request: events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/api/projects/billing-api/members/bob@example.com", RequestContext: paymentsAdmin},
+			status:  http.StatusForbidden,
+		},
+		{
+			// Rejected before Nobl9 is asked whether the user exists or is a member
+			name:    "remove non-member anonymously",
+			request: events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/api/projects/payments-api/members/nobody@example.com"},
+			status:  http.StatusForbidden,
+		},
+		{
+			// Allowed requests reach Nobl9, which fails here without credentials
+			name: "allowed create project",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "POST",
+				Path:           "/api/create-project",
+				Body:           `{"appID":"payments-api","userGroups":[{"userIds":"bob@example.com","role":"project-editor"}]}`,
+				RequestContext: paymentsAdmin,
+			},
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := handleRequest(context.Background(), tt.request)
+			if err != nil || response.StatusCode != tt.status {
+				t.Fatalf("handleRequest() = %d, %v, want %d: %s", response.StatusCode, err, tt.status, response.Body)
+			}
+			if tt.status != http.StatusForbidden {
+				return
+			}
+			var body Response
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil || body.Code != codeForbidden {
+				t.Errorf("response body = %s, want code %q", response.Body, codeForbidden)
+			}
+		})
+	}
+}
+
+func TestBatchAuthorization(t *testing.T) {
+	useAuthzPolicy(t, testAuthzPolicy)
+	captureMetrics(t)
+
+	caller := Caller{ID: "abc-123", Groups: []string{"payments-admins"}}
+	ctx := withCaller(context.Background(), caller)
+	results := createProjects(ctx, events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: batchProjectsPath}, []CreateProjectRequest{
+		{AppID: "billing-api", UserGroups: []UserGroup{{UserIDs: "bob@example.com", Role: "project-viewer"}}},
+	}, 1)
+
+	if len(results) != 1 || results[0].Status != createStatusForbidden || results[0].Code != codeForbidden {
+		t.Errorf("createProjects() = %+v, want a forbidden result", results)
+	}
+}
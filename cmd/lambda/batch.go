@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	batchProjectsPath       = "/api/projects:batch"
+	defaultBatchConcurrency = 4
+	maxBatchSize            = 100
+)
+
+// BatchCreateProjectsResponse reports the outcome of every project in a batch
+type BatchCreateProjectsResponse struct {
+	Response
+	Results []ProjectResult `json:"results"` // One result per requested project, in request order
+	Summary map[string]int  `json:"summary"` // Number of projects per status
+}
+
+// batchConcurrencyFromEnv reads how many projects are created in parallel from BATCH_CONCURRENCY
+func batchConcurrencyFromEnv() int {
+	value := os.Getenv("BATCH_CONCURRENCY")
+	if value == "" {
+		return defaultBatchConcurrency
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		slog.Warn("Invalid BATCH_CONCURRENCY, using default", "value", value, "default", defaultBatchConcurrency)
+		return defaultBatchConcurrency
+	}
+	return concurrency
+}
+
+// createProjects runs the create project pipeline for every request with at most concurrency in flight.
+// Each project is independent: one failure does not stop the others.
+func createProjects(ctx context.Context, request events.APIGatewayProxyRequest, reqs []CreateProjectRequest, concurrency int) []ProjectResult {
+	results := make([]ProjectResult, len(reqs))
+	seen := make(map[string]int, len(reqs))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+
+	for index, req := range reqs {
+		// Creating the same project twice in one batch would always conflict with itself
+		if first, ok := seen[req.AppID]; ok && req.AppID != "" {
+			results[index] = ProjectResult{
+				AppID:   req.AppID,
+				Status:  createStatusValidationFailed,
+				Code:    codeValidationFailed,
+				Message: fmt.Sprintf("Project '%s' is listed more than once in the batch (first at index %d)", req.AppID, first),
+			}
+			continue
+		}
+		seen[req.AppID] = index
+
+		dryRun, format, err := parseDryRunOptions(request, req)
+		if err != nil {
+			results[index] = ProjectResult{
+				AppID:   req.AppID,
+				Status:  createStatusValidationFailed,
+				Code:    codeValidationFailed,
+				Message: err.Error(),
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(index int, req CreateProjectRequest) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			slog.DebugContext(ctx, "Processing batch item", "index", index, "project", req.AppID)
+			results[index] = newProjectResult(req.AppID, createProject(ctx, req, dryRun), format)
+		}(index, req)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// handleBatchCreateProjects processes requests to create many projects at once
+func handleBatchCreateProjects(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Only allow POST requests
+	if request.HTTPMethod != "POST" {
+		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
+	}
+
+	var reqs []CreateProjectRequest
+	if err := json.Unmarshal([]byte(request.Body), &reqs); err != nil {
+		slog.InfoContext(ctx, "Invalid batch request body", "error", err)
+		return respondLambdaWithStatus(http.StatusBadRequest, false, "Invalid request body: expected a JSON array of projects: "+err.Error())
+	}
+
+	if len(reqs) == 0 {
+		return respondLambdaWithStatus(http.StatusBadRequest, false, "At least one project is required")
+	}
+	if len(reqs) > maxBatchSize {
+		return respondLambdaWithStatus(http.StatusBadRequest, false,
+			fmt.Sprintf("Too many projects in batch: %d. At most %d projects can be created at once", len(reqs), maxBatchSize))
+	}
+
+	// Reject invalid query options once rather than failing every project
+	if _, _, err := parseDryRunOptions(request, CreateProjectRequest{}); err != nil {
+		return respondLambdaWithStatus(http.StatusBadRequest, false, err.Error())
+	}
+
+	slog.InfoContext(ctx, "Processing batch of projects", "projects", len(reqs))
+	return respondProjectResults(createProjects(ctx, request, reqs, batchConcurrencyFromEnv()))
+}
+
+// respondProjectResults sends the per-project results of a batch with a summary by status.
+// The status code is 200 if every project succeeded and 207 otherwise.
+func respondProjectResults(results []ProjectResult) (events.APIGatewayProxyResponse, error) {
+	summary := make(map[string]int)
+	succeeded := 0
+	for _, result := range results {
+		summary[result.Status]++
+		if result.Succeeded() {
+			succeeded++
+		}
+	}
+
+	message := fmt.Sprintf("Processed %d projects: %d succeeded, %d failed", len(results), succeeded, len(results)-succeeded)
+	// 207 tells clients to inspect the per-project results
+	statusCode := http.StatusOK
+	if succeeded < len(results) {
+		statusCode = http.StatusMultiStatus
+	}
+
+	return respondLambdaJSON(statusCode, BatchCreateProjectsResponse{
+		Response: Response{
+			Success: succeeded == len(results),
+			Message: message,
+		},
+		Results: results,
+		Summary: summary,
+	})
+}
+
+// hasRetryableProjectResults reports whether a batch response body holds any project that may
+// succeed on a retry. Bodies that cannot be read are treated as retryable.
+func hasRetryableProjectResults(body string) bool {
+	var response BatchCreateProjectsResponse
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		return true
+	}
+	for _, result := range response.Results {
+		if result.Retryable() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHandleBatchCreateProjects(t *testing.T) {
+	tooMany := "[" + strings.TrimSuffix(strings.Repeat(`{"appID":"x"},`, maxBatchSize+1), ",") + "]"
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		query          map[string]string
+		expectedStatus int
+	}{
+		{"wrong method", "GET", "", nil, http.StatusMethodNotAllowed},
+		{"invalid body", "POST", `{"appID": "payments"}`, nil, http.StatusBadRequest},
+		{"empty batch", "POST", `[]`, nil, http.StatusBadRequest},
+		{"too many projects", "POST", tooMany, nil, http.StatusBadRequest},
+		{"invalid dryRun query", "POST", `[{"appID": "payments"}]`, map[string]string{"dryRun": "maybe"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		request := events.APIGatewayProxyRequest{HTTPMethod: tt.method, Body: tt.body, QueryStringParameters: tt.query}
+		response, err := handleBatchCreateProjects(context.Background(), request)
+		if err != nil {
+			t.Fatalf("handleBatchCreateProjects(%s) error = %v", tt.name, err)
+		}
+		if response.StatusCode != tt.expectedStatus {
+			t.Errorf("handleBatchCreateProjects(%s) status = %d, want %d", tt.name, response.StatusCode, tt.expectedStatus)
+		}
+	}
+}
+
+func TestHandleBatchCreateProjectsPerItemResults(t *testing.T) {
+	// Without credentials configured, valid projects fail before reaching Nobl9
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+
+	body := `[
+		{"appID": "payments", "userGroups": [{"userIds": "alice@example.com", "role": "project-owner"}]},
+		{"appID": "Bad_Project", "userGroups": [{"userIds": "alice@example.com", "role": "project-owner"}]},
+		{"appID": "payments", "userGroups": [{"userIds": "bob@example.com", "role": "project-viewer"}]},
+		{"appID": "checkout", "format": "toml", "userGroups": [{"userIds": "bob@example.com", "role": "project-viewer"}]}
+	]`
+
+	response, err := handleBatchCreateProjects(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: body})
+	if err != nil {
+		t.Fatalf("handleBatchCreateProjects() error = %v", err)
+	}
+	if response.StatusCode != http.StatusMultiStatus {
+		t.Errorf("handleBatchCreateProjects() status = %d, want %d", response.StatusCode, http.StatusMultiStatus)
+	}
+
+	var resp BatchCreateProjectsResponse
+	if err := json.Unmarshal([]byte(response.Body), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Success || len(resp.Results) != 4 {
+		t.Fatalf("handleBatchCreateProjects() = %+v, want 4 failed results", resp)
+	}
+
+	expected := []string{createStatusFailed, createStatusValidationFailed, createStatusValidationFailed, createStatusValidationFailed}
+	for index, result := range resp.Results {
+		if result.Status != expected[index] {
+			t.Errorf("result %d = %+v, want status %s", index, result, expected[index])
+		}
+	}
+	if !strings.Contains(resp.Results[2].Message, "more than once") {
+		t.Errorf("duplicate result message = %q", resp.Results[2].Message)
+	}
+	if resp.Summary[createStatusValidationFailed] != 3 || resp.Summary[createStatusFailed] != 1 {
+		t.Errorf("handleBatchCreateProjects() summary = %v", resp.Summary)
+	}
+}
+
+func TestCreateProjectsBoundedConcurrency(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+
+	var reqs []CreateProjectRequest
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, CreateProjectRequest{
+			AppID:      fmt.Sprintf("project-%d", i),
+			UserGroups: []UserGroup{{UserIDs: "alice@example.com", Role: "project-owner"}},
+		})
+	}
+
+	results := createProjects(context.Background(), events.APIGatewayProxyRequest{}, reqs, 3)
+	for index, result := range results {
+		if result.AppID != reqs[index].AppID || result.Status != createStatusFailed {
+			t.Errorf("createProjects() result %d = %+v", index, result)
+		}
+	}
+}
+
+func TestBatchConcurrencyFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"", defaultBatchConcurrency},
+		{"8", 8},
+		{"0", defaultBatchConcurrency},
+		{"many", defaultBatchConcurrency},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("BATCH_CONCURRENCY", tt.value)
+		if got := batchConcurrencyFromEnv(); got != tt.expected {
+			t.Errorf("batchConcurrencyFromEnv(%q) = %d, want %d", tt.value, got, tt.expected)
+		}
+	}
+}
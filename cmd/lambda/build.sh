@@ -44,7 +44,7 @@ go mod download
 
 # Build for Linux (required for AWS Lambda)
 echo -e "${YELLOW}Building for Linux...${NC}"
-GOOS=linux GOARCH=amd64 go build -o bootstrap main.go
+GOOS=linux GOARCH=amd64 go build -o bootstrap .
 
 # Check if build was successful
 if [ ! -f bootstrap ]; then
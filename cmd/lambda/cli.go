@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/nobl9/nobl9-go/manifest"
+)
+
+// ProjectResult reports the outcome of creating a single project
+type ProjectResult struct {
+	AppID    string       `json:"appID"`              // Name of the project
+	Status   string       `json:"status"`             // created, dry_run, conflict, validation_failed, lookup_failed, forbidden or failed
+	Message  string       `json:"message"`            // Human-readable outcome
+	Code     string       `json:"code,omitempty"`     // Stable error code for failures
+	Errors   []FieldError `json:"errors,omitempty"`   // Validation errors, or users that could not be found
+	Manifest string       `json:"manifest,omitempty"` // Generated manifest in dry-run mode
+	Merged   []UserMerge  `json:"merged,omitempty"`   // Users listed more than once who were merged into one role binding
+}
+
+// Succeeded reports whether the project was created or its dry run passed
+func (r ProjectResult) Succeeded() bool {
+	return r.Status == createStatusCreated || r.Status == createStatusDryRun
+}
+
+// Retryable reports whether the project failed for a reason that may clear up on a retry:
+// Nobl9 being unreachable, rate limiting or rejecting our credentials, an unexpected error,
+// or the authorization policy denying the caller
+func (r ProjectResult) Retryable() bool {
+	switch r.Code {
+	case codeNobl9Unauthorized, codeNobl9RateLimited, codeNobl9Unavailable, codeNobl9Error, codeForbidden:
+		return true
+	}
+	return r.Status == createStatusFailed && r.Code == ""
+}
+
+// newProjectResult converts a create project pipeline result into a ProjectResult,
+// encoding the manifest in the given format for dry runs
+func newProjectResult(appID string, result createProjectResult, format manifest.ObjectFormat) ProjectResult {
+	projectResult := ProjectResult{
+		AppID:   appID,
+		Status:  result.Status,
+		Message: result.Message,
+		Errors:  result.FieldErrors,
+		Merged:  result.Merges,
+	}
+
+	switch {
+	case result.Status == createStatusValidationFailed:
+		projectResult.Code = codeValidationFailed
+	case result.Status == createStatusConflict:
+		projectResult.Code = codeConflict
+	case result.Status == createStatusForbidden:
+		projectResult.Code = codeForbidden
+	case result.Err != nil:
+		projectResult.Code = classifyNobl9Error(result.Err).Code
+	case result.Status == createStatusLookupFailed:
+		projectResult.Code = codeUserNotFound
+	}
+
+	if result.Status == createStatusDryRun {
+		encoded, err := encodeManifest(result.Objects, format)
+		if err != nil {
+			projectResult.Status = createStatusFailed
+			projectResult.Message = fmt.Sprintf("Failed to encode manifest: %v", err)
+		} else {
+			projectResult.Manifest = encoded
+		}
+	}
+
+	return projectResult
+}
+
+// parseCreateProjectDocuments reads one or many create project requests from YAML or JSON.
+// The input may hold several YAML documents, and each document may be a single request or a list of them.
+func parseCreateProjectDocuments(data []byte) ([]CreateProjectRequest, error) {
+	var requests []CreateProjectRequest
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for index := 0; ; index++ {
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse document %d: %w", index, err)
+		}
+		if document == nil {
+			continue // Skip empty documents, e.g. a trailing "---"
+		}
+
+		// Decode through JSON so the request's JSON field names apply to YAML too
+		encoded, err := json.Marshal(document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document %d: %w", index, err)
+		}
+
+		if _, isList := document.([]interface{}); isList {
+			var list []CreateProjectRequest
+			if err := json.Unmarshal(encoded, &list); err != nil {
+				return nil, fmt.Errorf("failed to parse document %d: %w", index, err)
+			}
+			requests = append(requests, list...)
+			continue
+		}
+
+		var req CreateProjectRequest
+		if err := json.Unmarshal(encoded, &req); err != nil {
+			return nil, fmt.Errorf("failed to parse document %d: %w", index, err)
+		}
+		requests = append(requests, req)
+	}
+
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no projects found")
+	}
+	return requests, nil
+}
+
+// readInput reads a file, or standard input if path is "-"
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// printProjectResults writes per-project results as text or JSON
+func printProjectResults(w io.Writer, results []ProjectResult, output string) error {
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	for _, result := range results {
+		fmt.Fprintf(w, "%-18s %s: %s\n", strings.ToUpper(result.Status), result.AppID, result.Message)
+		for _, merge := range result.Merged {
+			fmt.Fprintf(w, "  merged %s (%s): requested %s, kept %s\n",
+				strings.Join(merge.Identifiers, ", "), merge.UserID, strings.Join(merge.RequestedRoles, ", "), merge.Role)
+		}
+		if result.Manifest != "" {
+			fmt.Fprintln(w, strings.TrimRight(result.Manifest, "\n"))
+		}
+	}
+	return nil
+}
+
+// cliCommands maps CLI command names to their implementations
+var cliCommands = map[string]func(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int{
+	"create": runCreateCommand,
+	"import": runImportCommand,
+}
+
+// runCreateCommand implements "create -f FILE": it creates every project in the file with the same
+// pipeline as the create-project endpoint and returns the process exit code
+func runCreateCommand(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return runProjectsCommand(ctx, "create", "YAML or JSON file with one or many create project requests",
+		parseCreateProjectDocuments, args, stdin, stdout, stderr)
+}
+
+// runProjectsCommand parses the command's flags, reads the projects from the input file with parse
+// and creates each of them, printing a result per project
+func runProjectsCommand(ctx context.Context, name, fileUsage string, parse func([]byte) ([]CreateProjectRequest, error),
+	args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("f", "", fileUsage+" (\"-\" for stdin)")
+	dryRun := flags.Bool("dry-run", false, "validate and look up users, then print the manifests instead of applying them")
+	format := flags.String("format", "yaml", "dry-run manifest format: yaml or json")
+	output := flags.String("o", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *file == "" {
+		fmt.Fprintln(stderr, "Error: -f is required")
+		flags.Usage()
+		return 2
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(stderr, "Error: invalid output format '%s'. Must be one of: text, json\n", *output)
+		return 2
+	}
+	manifestFormat, err := manifest.ParseObjectFormat(*format)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: invalid format '%s'. Must be one of: yaml, json\n", *format)
+		return 2
+	}
+
+	data, err := readInput(*file, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	requests, err := parse(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s: %v\n", *file, err)
+		return 1
+	}
+
+	results := make([]ProjectResult, 0, len(requests))
+	exitCode := 0
+	for _, req := range requests {
+		result := newProjectResult(req.AppID, createProject(ctx, req, *dryRun || req.DryRun), manifestFormat)
+		if !result.Succeeded() {
+			exitCode = 1
+		}
+		results = append(results, result)
+	}
+
+	if err := printProjectResults(stdout, results, *output); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return exitCode
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nobl9/nobl9-go/manifest"
+	v1alphaProject "github.com/nobl9/nobl9-go/manifest/v1alpha/project"
+)
+
+func TestParseCreateProjectDocuments(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectedIDs []string
+		expectError bool
+	}{
+		{
+			name: "single YAML document",
+			input: `appID: payments
+description: Payments team
+userGroups:
+  - userIds: alice@example.com,bob@example.com
+    role: project-owner
+`,
+			expectedIDs: []string{"payments"},
+		},
+		{
+			name: "multiple YAML documents",
+			input: `appID: payments
+userGroups:
+  - userIds: alice@example.com
+    role: project-owner
+---
+appID: checkout
+userGroups:
+  - userIds: carol@example.com
+    role: project-owner
+---
+`,
+			expectedIDs: []string{"payments", "checkout"},
+		},
+		{
+			name: "YAML list",
+			input: `- appID: payments
+- appID: checkout
+`,
+			expectedIDs: []string{"payments", "checkout"},
+		},
+		{
+			name:        "JSON object",
+			input:       `{"appID": "payments", "userGroups": [{"userIds": "alice@example.com", "role": "project-owner"}]}`,
+			expectedIDs: []string{"payments"},
+		},
+		{
+			name:        "JSON array",
+			input:       `[{"appID": "payments"}, {"appID": "checkout"}]`,
+			expectedIDs: []string{"payments", "checkout"},
+		},
+		{name: "empty", input: "", expectError: true},
+		{name: "wrong type", input: `appID: [payments]`, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, err := parseCreateProjectDocuments([]byte(tt.input))
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("parseCreateProjectDocuments() should fail, got %+v", requests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCreateProjectDocuments() error = %v", err)
+			}
+
+			var ids []string
+			for _, req := range requests {
+				ids = append(ids, req.AppID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.expectedIDs, ",") {
+				t.Errorf("parseCreateProjectDocuments() projects = %v, want %v", ids, tt.expectedIDs)
+			}
+		})
+	}
+
+	requests, _ := parseCreateProjectDocuments([]byte(tests[0].input))
+	if requests[0].Description != "Payments team" || len(requests[0].UserGroups) != 1 ||
+		requests[0].UserGroups[0].UserIDs != "alice@example.com,bob@example.com" || requests[0].UserGroups[0].Role != "project-owner" {
+		t.Errorf("parseCreateProjectDocuments() = %+v", requests[0])
+	}
+}
+
+func TestNewProjectResult(t *testing.T) {
+	project := v1alphaProject.New(v1alphaProject.Metadata{Name: "payments"}, v1alphaProject.Spec{})
+	result := newProjectResult("payments", createProjectResult{
+		Status:  createStatusDryRun,
+		Message: "Dry run for project 'payments'",
+		Objects: []manifest.Object{project},
+	}, manifest.ObjectFormatYAML)
+
+	if !result.Succeeded() || !strings.Contains(result.Manifest, "kind: Project") {
+		t.Errorf("newProjectResult() dry run = %+v", result)
+	}
+
+	result = newProjectResult("payments", createProjectResult{
+		Status:  createStatusConflict,
+		Message: "Project 'payments' already exists",
+	}, manifest.ObjectFormatYAML)
+	if result.Succeeded() || result.Code != codeConflict {
+		t.Errorf("newProjectResult() conflict = %+v", result)
+	}
+}
+
+func TestRunCreateCommand(t *testing.T) {
+	// Without credentials configured, valid projects fail before reaching Nobl9
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "projects.yaml")
+	os.WriteFile(file, []byte(`appID: Bad_Project
+userGroups:
+  - userIds: alice@example.com
+    role: project-owner
+---
+appID: payments
+userGroups:
+  - userIds: bad@
+    role: project-admin
+`), 0o600)
+
+	var stdout, stderr bytes.Buffer
+	exitCode := runCreateCommand(context.Background(), []string{"-f", file, "-o", "json"}, nil, &stdout, &stderr)
+	if exitCode != 1 {
+		t.Errorf("runCreateCommand() exit code = %d, want 1 (stderr: %s)", exitCode, stderr.String())
+	}
+
+	var results []ProjectResult
+	if err := json.Unmarshal(stdout.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to parse output %q: %v", stdout.String(), err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("runCreateCommand() results = %+v, want 2", results)
+	}
+	for _, result := range results {
+		if result.Status != createStatusValidationFailed || result.Code != codeValidationFailed || len(result.Errors) == 0 {
+			t.Errorf("runCreateCommand() result = %+v, want validation failure", result)
+		}
+	}
+	if len(results[1].Errors) != 2 {
+		t.Errorf("runCreateCommand() errors for %s = %+v, want 2", results[1].AppID, results[1].Errors)
+	}
+
+	// Text output and stdin input
+	stdout.Reset()
+	stdin := strings.NewReader(`{"appID": "x", "userGroups": []}`)
+	if exitCode := runCreateCommand(context.Background(), []string{"-f", "-"}, stdin, &stdout, &stderr); exitCode != 1 {
+		t.Errorf("runCreateCommand() from stdin exit code = %d, want 1", exitCode)
+	}
+	if !strings.HasPrefix(stdout.String(), "VALIDATION_FAILED") {
+		t.Errorf("runCreateCommand() text output = %q", stdout.String())
+	}
+}
+
+func TestRunCreateCommandUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"missing file flag", []string{}, 2},
+		{"unknown flag", []string{"-x"}, 2},
+		{"invalid output", []string{"-f", "projects.yaml", "-o", "xml"}, 2},
+		{"invalid format", []string{"-f", "projects.yaml", "-format", "toml"}, 2},
+		{"missing file", []string{"-f", filepath.Join(t.TempDir(), "missing.yaml")}, 1},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if exitCode := runCreateCommand(context.Background(), tt.args, nil, &stdout, &stderr); exitCode != tt.expected {
+			t.Errorf("runCreateCommand(%s) exit code = %d, want %d", tt.name, exitCode, tt.expected)
+		}
+	}
+}
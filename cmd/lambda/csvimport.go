@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const importProjectsPath = "/api/projects:import"
+
+// CSV columns, in the order used when the file has no header row
+const (
+	csvColumnProject     = "project"
+	csvColumnDescription = "description"
+	csvColumnEmail       = "email"
+	csvColumnRole        = "role"
+)
+
+// csvColumnAliases maps accepted header names to CSV columns
+var csvColumnAliases = map[string]string{
+	"project":     csvColumnProject,
+	"appid":       csvColumnProject,
+	"description": csvColumnDescription,
+	"email":       csvColumnEmail,
+	"user":        csvColumnEmail,
+	"userid":      csvColumnEmail,
+	"role":        csvColumnRole,
+}
+
+// csvValidationError is returned when one or more CSV rows are invalid
+type csvValidationError struct {
+	fieldErrors []FieldError
+}
+
+// Error returns every row problem as a single message
+func (e *csvValidationError) Error() string {
+	return validationSummary(e.fieldErrors)
+}
+
+// csvColumns finds the position of each column from a header row.
+// It returns false if the record is not a header, in which case the default column order applies.
+func csvColumns(record []string) (map[string]int, bool, error) {
+	columns := make(map[string]int)
+	for index, name := range record {
+		column, ok := csvColumnAliases[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			continue
+		}
+		columns[column] = index
+	}
+
+	if _, isHeader := columns[csvColumnProject]; !isHeader {
+		return map[string]int{csvColumnProject: 0, csvColumnDescription: 1, csvColumnEmail: 2, csvColumnRole: 3}, false, nil
+	}
+	for _, required := range []string{csvColumnEmail, csvColumnRole} {
+		if _, ok := columns[required]; !ok {
+			return nil, true, fmt.Errorf("header is missing the '%s' column", required)
+		}
+	}
+	return columns, true, nil
+}
+
+// validateCSVRow validates the project, email and role of a single CSV row
+func validateCSVRow(line int, project, email, role string) []FieldError {
+	var fieldErrors []FieldError
+	addError := func(column, code, message, value string) {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   fmt.Sprintf("line[%d].%s", line, column),
+			Code:    code,
+			Message: fmt.Sprintf("Line %d: %s", line, message),
+			Value:   value,
+		})
+	}
+
+	if err := validateProjectName(project); err != nil {
+		addError(csvColumnProject, validationErrorCode(err), err.Error(), project)
+	}
+
+	if email == "" {
+		addError(csvColumnEmail, codeRequired, "Email is required", "")
+	} else if err := validateUserIdentifier(email); err != nil {
+		addError(csvColumnEmail, validationErrorCode(err), err.Error(), email)
+	}
+
+	if !validRoles[role] {
+		addError(csvColumnRole, codeInvalidRole, fmt.Sprintf("Invalid role '%s'. Must be one of: %s", role, getValidRoles()), role)
+	}
+
+	return fieldErrors
+}
+
+// parseProjectsCSV reads project, description, email and role rows and groups them into one
+// create project request per project, with one user group per role. The header row is optional;
+// with a header, columns may appear in any order. Every invalid row is reported with its line number.
+func parseProjectsCSV(data []byte) ([]CreateProjectRequest, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1 // Column counts are checked per row to report line numbers
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var (
+		requests    []CreateProjectRequest
+		fieldErrors []FieldError
+		columns     map[string]int
+		width       = 4                    // Number of columns every row must have
+		projects    = make(map[string]int) // Project name to index in requests
+		groups      = make(map[string]int) // Project and role to index in the project's user groups
+		first       = true
+	)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first {
+			first = false
+			var isHeader bool
+			if columns, isHeader, err = csvColumns(record); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if isHeader {
+				width = len(record)
+				continue
+			}
+		}
+
+		value := func(column string) string {
+			index, ok := columns[column]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		if len(record) != width {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   fmt.Sprintf("line[%d]", line),
+				Code:    codeInvalidFormat,
+				Message: fmt.Sprintf("Line %d: expected %d columns, got %d", line, width, len(record)),
+			})
+			continue
+		}
+
+		project, description, email, role := value(csvColumnProject), value(csvColumnDescription), value(csvColumnEmail), value(csvColumnRole)
+		if rowErrors := validateCSVRow(line, project, email, role); len(rowErrors) > 0 {
+			fieldErrors = append(fieldErrors, rowErrors...)
+			continue
+		}
+
+		projectIndex, ok := projects[project]
+		if !ok {
+			projectIndex = len(requests)
+			projects[project] = projectIndex
+			requests = append(requests, CreateProjectRequest{AppID: project})
+		}
+		req := &requests[projectIndex]
+
+		// The description may be given on any row of the project, but must not contradict itself
+		switch {
+		case description == "":
+		case req.Description == "":
+			req.Description = description
+		case req.Description != description:
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   fmt.Sprintf("line[%d].%s", line, csvColumnDescription),
+				Code:    codeInvalidFormat,
+				Message: fmt.Sprintf("Line %d: description of project '%s' differs from an earlier row", line, project),
+				Value:   description,
+			})
+			continue
+		}
+
+		groupKey := project + "\x00" + role
+		groupIndex, ok := groups[groupKey]
+		if !ok {
+			groupIndex = len(req.UserGroups)
+			groups[groupKey] = groupIndex
+			req.UserGroups = append(req.UserGroups, UserGroup{Role: role})
+		}
+		group := &req.UserGroups[groupIndex]
+		if group.UserIDs == "" {
+			group.UserIDs = email
+		} else {
+			group.UserIDs += "," + email
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, &csvValidationError{fieldErrors: fieldErrors}
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no projects found")
+	}
+	return requests, nil
+}
+
+// handleImportProjects processes requests to create projects from a CSV body
+func handleImportProjects(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Only allow POST requests
+	if request.HTTPMethod != "POST" {
+		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
+	}
+
+	reqs, err := parseProjectsCSV([]byte(request.Body))
+	if err != nil {
+		var csvErr *csvValidationError
+		if errors.As(err, &csvErr) {
+			return respondValidationErrors(csvErr.fieldErrors)
+		}
+		slog.InfoContext(ctx, "Invalid CSV body", "error", err)
+		return respondLambdaWithStatus(http.StatusBadRequest, false, "Invalid CSV: "+err.Error())
+	}
+
+	if len(reqs) > maxBatchSize {
+		return respondLambdaWithStatus(http.StatusBadRequest, false,
+			fmt.Sprintf("Too many projects in CSV: %d. At most %d projects can be created at once", len(reqs), maxBatchSize))
+	}
+
+	if _, _, err := parseDryRunOptions(request, CreateProjectRequest{}); err != nil {
+		return respondLambdaWithStatus(http.StatusBadRequest, false, err.Error())
+	}
+
+	slog.InfoContext(ctx, "Importing projects from CSV", "projects", len(reqs))
+	return respondProjectResults(createProjects(ctx, request, reqs, batchConcurrencyFromEnv()))
+}
+
+// runImportCommand implements "import -f FILE": it creates every project in a CSV file with the same
+// pipeline as the create-project endpoint and returns the process exit code
+func runImportCommand(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return runProjectsCommand(ctx, "import", "CSV file with project, description, email and role columns",
+		parseProjectsCSV, args, stdin, stdout, stderr)
+}
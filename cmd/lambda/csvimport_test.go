@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestParseProjectsCSV(t *testing.T) {
+	input := `project,description,email,role
+payments,Payments team,alice@example.com,project-owner
+payments,,bob@example.com,project-owner
+# Viewers are added separately
+payments,,carol@example.com,project-viewer
+checkout,,dave@example.com,project-owner
+`
+	requests, err := parseProjectsCSV([]byte(input))
+	if err != nil {
+		t.Fatalf("parseProjectsCSV() error = %v", err)
+	}
+
+	expected := []CreateProjectRequest{
+		{AppID: "payments", Description: "Payments team", UserGroups: []UserGroup{
+			{UserIDs: "alice@example.com,bob@example.com", Role: "project-owner"},
+			{UserIDs: "carol@example.com", Role: "project-viewer"},
+		}},
+		{AppID: "checkout", UserGroups: []UserGroup{{UserIDs: "dave@example.com", Role: "project-owner"}}},
+	}
+	got, _ := json.Marshal(requests)
+	want, _ := json.Marshal(expected)
+	if string(got) != string(want) {
+		t.Errorf("parseProjectsCSV() = %s, want %s", got, want)
+	}
+
+	// Without a header the default column order applies; with one, columns may be reordered
+	for _, input := range []string{
+		"payments,,alice@example.com,project-owner\n",
+		"Role,Email,Project\nproject-owner,alice@example.com,payments\n",
+	} {
+		requests, err := parseProjectsCSV([]byte(input))
+		if err != nil || len(requests) != 1 || requests[0].UserGroups[0].UserIDs != "alice@example.com" {
+			t.Errorf("parseProjectsCSV(%q) = %+v, %v", input, requests, err)
+		}
+	}
+}
+
+func TestParseProjectsCSVErrors(t *testing.T) {
+	input := `project,description,email,role
+payments,Payments team,alice@example.com,project-owner
+Bad_Project,,bob@example.com,project-owner
+payments,,not-an-email@,project-admin
+payments,Other team,carol@example.com,project-owner
+payments,alice@example.com,project-owner
+`
+	_, err := parseProjectsCSV([]byte(input))
+
+	var csvErr *csvValidationError
+	if !errors.As(err, &csvErr) {
+		t.Fatalf("parseProjectsCSV() error = %v, want csvValidationError", err)
+	}
+
+	expected := []string{"line[3].project", "line[4].email", "line[4].role", "line[5].description", "line[6]"}
+	if len(csvErr.fieldErrors) != len(expected) {
+		t.Fatalf("parseProjectsCSV() errors = %+v, want %d", csvErr.fieldErrors, len(expected))
+	}
+	for index, fieldError := range csvErr.fieldErrors {
+		if fieldError.Field != expected[index] {
+			t.Errorf("error %d field = %q, want %q", index, fieldError.Field, expected[index])
+		}
+	}
+	if !strings.HasPrefix(csvErr.fieldErrors[0].Message, "Line 3: ") {
+		t.Errorf("error message = %q, want line number prefix", csvErr.fieldErrors[0].Message)
+	}
+
+	for _, input := range []string{"", "project,description\npayments,\n", "payments,\"unterminated\n"} {
+		if _, err := parseProjectsCSV([]byte(input)); err == nil {
+			t.Errorf("parseProjectsCSV(%q) should fail", input)
+		}
+	}
+}
+
+func TestHandleImportProjects(t *testing.T) {
+	// Without credentials configured, valid projects fail before reaching Nobl9
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"wrong method", "GET", "", http.StatusMethodNotAllowed, ""},
+		{"invalid rows", "POST", "Bad_Project,,alice@example.com,project-owner\n", http.StatusBadRequest, codeValidationFailed},
+		{"malformed CSV", "POST", "payments,\"unterminated\n", http.StatusBadRequest, ""},
+		{"valid rows", "POST", "payments,,alice@example.com,project-owner\n", http.StatusMultiStatus, ""},
+	}
+
+	for _, tt := range tests {
+		response, err := handleImportProjects(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: tt.method, Body: tt.body})
+		if err != nil {
+			t.Fatalf("handleImportProjects(%s) error = %v", tt.name, err)
+		}
+		var resp Response
+		json.Unmarshal([]byte(response.Body), &resp)
+		if response.StatusCode != tt.expectedStatus || resp.Code != tt.expectedCode {
+			t.Errorf("handleImportProjects(%s) = %d %+v, want %d %q", tt.name, response.StatusCode, resp, tt.expectedStatus, tt.expectedCode)
+		}
+	}
+}
+
+func TestRunImportCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "projects.csv")
+	os.WriteFile(file, []byte("payments,,bad@,project-owner\n"), 0o600)
+
+	var stdout, stderr bytes.Buffer
+	if exitCode := runImportCommand(context.Background(), []string{"-f", file, "-dry-run"}, nil, &stdout, &stderr); exitCode != 1 {
+		t.Errorf("runImportCommand() exit code = %d, want 1", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "Line 1: Invalid email format") {
+		t.Errorf("runImportCommand() stderr = %q, want line-numbered error", stderr.String())
+	}
+}
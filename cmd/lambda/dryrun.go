@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nobl9/nobl9-go/manifest"
+	"github.com/nobl9/nobl9-go/sdk"
+)
+
+// DryRunResponse defines the response for a create-project dry run
+type DryRunResponse struct {
+	Response
+	DryRun   bool        `json:"dryRun"`           // Always true, nothing was applied
+	Format   string      `json:"format"`           // Format of the manifest: "yaml" or "json"
+	Objects  int         `json:"objects"`          // Number of objects in the manifest
+	Manifest string      `json:"manifest"`         // sloctl-compatible manifest of all objects
+	Merged   []UserMerge `json:"merged,omitempty"` // Users listed more than once who were merged into one role binding
+}
+
+// parseDryRunOptions determines whether the request is a dry run and in which format the
+// manifests should be returned. Query string parameters take precedence over body fields.
+func parseDryRunOptions(request events.APIGatewayProxyRequest, req CreateProjectRequest) (bool, manifest.ObjectFormat, error) {
+	dryRun := req.DryRun
+	if value, ok := request.QueryStringParameters["dryRun"]; ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, 0, fmt.Errorf("Invalid dryRun value '%s'. Must be true or false", value)
+		}
+		dryRun = parsed
+	}
+
+	formatName := req.Format
+	if value, ok := request.QueryStringParameters["format"]; ok {
+		formatName = value
+	}
+	if formatName == "" {
+		return dryRun, manifest.ObjectFormatYAML, nil
+	}
+
+	format, err := manifest.ParseObjectFormat(formatName)
+	if err != nil {
+		return false, 0, fmt.Errorf("Invalid format '%s'. Must be one of: yaml, json", formatName)
+	}
+	return dryRun, format, nil
+}
+
+// encodeManifest serializes objects the same way sloctl does for the given format
+func encodeManifest(objects []manifest.Object, format manifest.ObjectFormat) (string, error) {
+	var buf bytes.Buffer
+	if err := sdk.EncodeObjects(objects, &buf, format); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// respondDryRun returns the generated manifests without applying them
+func respondDryRun(projectName string, objects []manifest.Object, format manifest.ObjectFormat, merges []UserMerge) (events.APIGatewayProxyResponse, error) {
+	encoded, err := encodeManifest(objects, format)
+	if err != nil {
+		slog.Error("Failed to encode manifest", "project", projectName, "error", err)
+		return respondLambdaWithStatus(http.StatusInternalServerError, false, fmt.Sprintf("Failed to encode manifest: %v", err))
+	}
+
+	message := fmt.Sprintf("Dry run for project '%s': %d objects would be applied (1 project + %d role bindings)",
+		projectName, len(objects), len(objects)-1)
+	return respondLambdaJSON(http.StatusOK, DryRunResponse{
+		Response: Response{
+			Success: true,
+			Message: message,
+		},
+		DryRun:   true,
+		Format:   strings.ToLower(format.String()),
+		Objects:  len(objects),
+		Manifest: encoded,
+		Merged:   merges,
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nobl9/nobl9-go/manifest"
+	v1alphaProject "github.com/nobl9/nobl9-go/manifest/v1alpha/project"
+)
+
+func TestParseDryRunOptions(t *testing.T) {
+	tests := []struct {
+		query          map[string]string
+		req            CreateProjectRequest
+		expectedDryRun bool
+		expectedFormat manifest.ObjectFormat
+		expectErr      bool
+	}{
+		{nil, CreateProjectRequest{}, false, manifest.ObjectFormatYAML, false},
+		{nil, CreateProjectRequest{DryRun: true}, true, manifest.ObjectFormatYAML, false},
+		{nil, CreateProjectRequest{DryRun: true, Format: "json"}, true, manifest.ObjectFormatJSON, false},
+		{map[string]string{"dryRun": "true"}, CreateProjectRequest{}, true, manifest.ObjectFormatYAML, false},
+		{map[string]string{"dryRun": "false"}, CreateProjectRequest{DryRun: true}, false, manifest.ObjectFormatYAML, false},
+		{map[string]string{"dryRun": "true", "format": "JSON"}, CreateProjectRequest{Format: "yaml"}, true, manifest.ObjectFormatJSON, false},
+		{map[string]string{"dryRun": "maybe"}, CreateProjectRequest{}, false, 0, true},
+		{nil, CreateProjectRequest{DryRun: true, Format: "toml"}, false, 0, true},
+	}
+
+	for _, tt := range tests {
+		request := events.APIGatewayProxyRequest{QueryStringParameters: tt.query}
+		dryRun, format, err := parseDryRunOptions(request, tt.req)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("parseDryRunOptions(%v, %+v) error = %v, want error %v", tt.query, tt.req, err, tt.expectErr)
+			continue
+		}
+		if dryRun != tt.expectedDryRun || format != tt.expectedFormat {
+			t.Errorf("parseDryRunOptions(%v, %+v) = %v, %v, want %v, %v", tt.query, tt.req, dryRun, format, tt.expectedDryRun, tt.expectedFormat)
+		}
+	}
+}
+
+func TestRespondDryRun(t *testing.T) {
+	project := v1alphaProject.New(
+		v1alphaProject.Metadata{Name: "my-project"},
+		v1alphaProject.Spec{Description: "Payments monitoring"},
+	)
+	roleBinding := newRoleBinding("my-project", "user-1", "project-owner")
+	objects := []manifest.Object{project, roleBinding}
+
+	// YAML output
+	response, err := respondDryRun("my-project", objects, manifest.ObjectFormatYAML, nil)
+	if err != nil {
+		t.Errorf("respondDryRun() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("respondDryRun() status = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+
+	var resp DryRunResponse
+	if err := json.Unmarshal([]byte(response.Body), &resp); err != nil {
+		t.Errorf("Failed to parse response: %v", err)
+	}
+
+	if !resp.Success || !resp.DryRun || resp.Format != "yaml" || resp.Objects != 2 {
+		t.Errorf("respondDryRun() response = %+v", resp)
+	}
+
+	for _, expected := range []string{"kind: Project", "kind: RoleBinding", "name: my-project", "roleRef: project-owner"} {
+		if !strings.Contains(resp.Manifest, expected) {
+			t.Errorf("respondDryRun() YAML manifest missing %q:\n%s", expected, resp.Manifest)
+		}
+	}
+
+	// JSON output
+	response, err = respondDryRun("my-project", objects, manifest.ObjectFormatJSON, nil)
+	if err != nil {
+		t.Errorf("respondDryRun() error = %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(response.Body), &resp); err != nil {
+		t.Errorf("Failed to parse response: %v", err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Manifest), &decoded); err != nil {
+		t.Errorf("respondDryRun() JSON manifest is not valid JSON: %v", err)
+	}
+
+	if len(decoded) != 2 || decoded[0]["kind"] != "Project" || decoded[1]["kind"] != "RoleBinding" {
+		t.Errorf("respondDryRun() JSON manifest = %v", decoded)
+	}
+}
+
+func TestHandleCreateProjectDryRunValidation(t *testing.T) {
+	// Test invalid format
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "POST",
+		Path:                  "/api/create-project",
+		Body:                  `{"appID": "valid-project", "userGroups": [{"userIds": "user@example.com", "role": "project-owner"}]}`,
+		QueryStringParameters: map[string]string{"dryRun": "true", "format": "xml"},
+	}
+
+	response, err := handleCreateProject(context.Background(), request)
+	if err != nil {
+		t.Errorf("handleCreateProject() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("handleCreateProject() status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+}
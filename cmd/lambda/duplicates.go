@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Values of the duplicateUsers request option
+const (
+	duplicateUsersMerge  = "merge"  // Keep a single role per user, the one with the highest precedence (default)
+	duplicateUsersReject = "reject" // Fail the request if a user is listed with different roles
+)
+
+// codeConflictingRoles marks users listed with different roles when duplicates are rejected
+const codeConflictingRoles = "conflicting_roles"
+
+// rolePrecedence ranks project roles so that a user listed with several roles keeps the most privileged one
+var rolePrecedence = map[string]int{
+	"project-viewer": 1,
+	"project-editor": 2,
+	"project-owner":  3,
+}
+
+// userAssignment is a role requested for a resolved user
+type userAssignment struct {
+	Identifier string // Email or user ID as listed in the request
+	UserID     string // Resolved Nobl9 user ID
+	Role       string // Requested role
+	Field      string // Path of the identifier in the request, e.g. userGroups[1].userIds[0]
+}
+
+// UserMerge reports a user who was listed more than once in a request and merged into one role binding
+type UserMerge struct {
+	UserID         string   `json:"userId"`         // Resolved Nobl9 user ID
+	Identifiers    []string `json:"identifiers"`    // Emails or user IDs the user was listed as
+	RequestedRoles []string `json:"requestedRoles"` // Every role requested for the user
+	Role           string   `json:"role"`           // Role that was kept
+}
+
+// validateDuplicateUsersOption checks the duplicateUsers request option
+func validateDuplicateUsersOption(value string) []FieldError {
+	if value == "" || value == duplicateUsersMerge || value == duplicateUsersReject {
+		return nil
+	}
+	return []FieldError{{
+		Field:   "duplicateUsers",
+		Code:    codeInvalidFormat,
+		Message: fmt.Sprintf("Invalid duplicateUsers value '%s'. Must be one of: %s, %s", value, duplicateUsersMerge, duplicateUsersReject),
+		Value:   value,
+	}}
+}
+
+// appendUnique appends value to values unless it is already present
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// normalizeAssignments merges assignments for the same resolved user into one, keeping the role with
+// the highest precedence (owner > editor > viewer). Users keep the position of their first listing.
+// If reject is set, a user listed with different roles is reported as a conflict instead.
+func normalizeAssignments(assignments []userAssignment, reject bool) ([]userAssignment, []UserMerge, []FieldError) {
+	var order []string
+	byUser := make(map[string][]userAssignment)
+	for _, assignment := range assignments {
+		if _, ok := byUser[assignment.UserID]; !ok {
+			order = append(order, assignment.UserID)
+		}
+		byUser[assignment.UserID] = append(byUser[assignment.UserID], assignment)
+	}
+
+	var normalized []userAssignment
+	var merges []UserMerge
+	var conflicts []FieldError
+	for _, userID := range order {
+		listed := byUser[userID]
+		kept := listed[0]
+		merge := UserMerge{UserID: userID}
+		for _, assignment := range listed {
+			merge.Identifiers = appendUnique(merge.Identifiers, assignment.Identifier)
+			merge.RequestedRoles = appendUnique(merge.RequestedRoles, assignment.Role)
+			if rolePrecedence[assignment.Role] > rolePrecedence[kept.Role] {
+				kept = assignment
+			}
+		}
+		normalized = append(normalized, kept)
+
+		if len(listed) == 1 {
+			continue
+		}
+
+		if reject && len(merge.RequestedRoles) > 1 {
+			var listings []string
+			for _, assignment := range listed {
+				listings = append(listings, fmt.Sprintf("%s (%s)", assignment.Role, assignment.Field))
+			}
+			conflicts = append(conflicts, FieldError{
+				Field:   listed[len(listed)-1].Field,
+				Code:    codeConflictingRoles,
+				Message: fmt.Sprintf("User '%s' is listed with conflicting roles: %s", kept.Identifier, strings.Join(listings, ", ")),
+				Value:   kept.Identifier,
+			})
+			continue
+		}
+
+		merge.Role = kept.Role
+		merges = append(merges, merge)
+	}
+
+	if len(conflicts) > 0 {
+		return nil, nil, conflicts
+	}
+	return normalized, merges, nil
+}
+
+// logMerges logs every user who was merged into one role binding
+func logMerges(ctx context.Context, merges []UserMerge) {
+	for _, merge := range merges {
+		slog.InfoContext(ctx, "Merged user listed more than once", "userId", merge.UserID, "requestedRoles", merge.RequestedRoles, "role", merge.Role)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAssignments(t *testing.T) {
+	assignments := []userAssignment{
+		{Identifier: "alice@example.com", UserID: "00u1", Role: "project-viewer", Field: "userGroups[0].userIds[0]"},
+		{Identifier: "bob@example.com", UserID: "00u2", Role: "project-viewer", Field: "userGroups[0].userIds[1]"},
+		{Identifier: "carol@example.com", UserID: "00u3", Role: "project-editor", Field: "userGroups[1].userIds[0]"},
+		{Identifier: "00u1", UserID: "00u1", Role: "project-owner", Field: "userGroups[2].userIds[0]"},
+		{Identifier: "Alice@example.com", UserID: "00u1", Role: "project-editor", Field: "userGroups[1].userIds[1]"},
+		{Identifier: "bob@example.com", UserID: "00u2", Role: "project-viewer", Field: "userGroups[3].userIds[0]"},
+	}
+
+	normalized, merges, conflicts := normalizeAssignments(assignments, false)
+	if len(conflicts) != 0 {
+		t.Fatalf("normalizeAssignments() conflicts = %+v, want none when merging", conflicts)
+	}
+
+	var got []string
+	for _, assignment := range normalized {
+		got = append(got, assignment.UserID+"="+assignment.Role)
+	}
+	expected := "00u1=project-owner,00u2=project-viewer,00u3=project-editor"
+	if strings.Join(got, ",") != expected {
+		t.Errorf("normalizeAssignments() = %v, want %s", got, expected)
+	}
+
+	if len(merges) != 2 {
+		t.Fatalf("normalizeAssignments() merges = %+v, want 2", merges)
+	}
+	if merges[0].UserID != "00u1" || merges[0].Role != "project-owner" ||
+		strings.Join(merges[0].Identifiers, ",") != "alice@example.com,00u1,Alice@example.com" ||
+		strings.Join(merges[0].RequestedRoles, ",") != "project-viewer,project-owner,project-editor" {
+		t.Errorf("normalizeAssignments() merge = %+v", merges[0])
+	}
+	if merges[1].UserID != "00u2" || merges[1].Role != "project-viewer" || len(merges[1].Identifiers) != 1 {
+		t.Errorf("normalizeAssignments() merge = %+v", merges[1])
+	}
+}
+
+func TestNormalizeAssignmentsReject(t *testing.T) {
+	assignments := []userAssignment{
+		{Identifier: "alice@example.com", UserID: "00u1", Role: "project-viewer", Field: "userGroups[0].userIds[0]"},
+		{Identifier: "bob@example.com", UserID: "00u2", Role: "project-editor", Field: "userGroups[0].userIds[1]"},
+		{Identifier: "alice@example.com", UserID: "00u1", Role: "project-owner", Field: "userGroups[1].userIds[0]"},
+		{Identifier: "bob@example.com", UserID: "00u2", Role: "project-editor", Field: "userGroups[2].userIds[0]"},
+	}
+
+	normalized, merges, conflicts := normalizeAssignments(assignments, true)
+	if normalized != nil || merges != nil {
+		t.Errorf("normalizeAssignments() = %+v, %+v, want nothing on conflict", normalized, merges)
+	}
+
+	// Listing a user twice with the same role is not a conflict
+	if len(conflicts) != 1 {
+		t.Fatalf("normalizeAssignments() conflicts = %+v, want 1", conflicts)
+	}
+	conflict := conflicts[0]
+	if conflict.Code != codeConflictingRoles || conflict.Field != "userGroups[1].userIds[0]" || conflict.Value != "alice@example.com" {
+		t.Errorf("normalizeAssignments() conflict = %+v", conflict)
+	}
+	expected := "User 'alice@example.com' is listed with conflicting roles: project-viewer (userGroups[0].userIds[0]), project-owner (userGroups[1].userIds[0])"
+	if conflict.Message != expected {
+		t.Errorf("normalizeAssignments() message = %q, want %q", conflict.Message, expected)
+	}
+
+	// Without conflicting roles, rejecting still merges
+	normalized, merges, conflicts = normalizeAssignments(assignments[1:2], true)
+	if len(normalized) != 1 || len(merges) != 0 || len(conflicts) != 0 {
+		t.Errorf("normalizeAssignments() = %+v, %+v, %+v", normalized, merges, conflicts)
+	}
+}
+
+func TestValidateDuplicateUsersOption(t *testing.T) {
+	for _, value := range []string{"", duplicateUsersMerge, duplicateUsersReject} {
+		if fieldErrors := validateDuplicateUsersOption(value); len(fieldErrors) != 0 {
+			t.Errorf("validateDuplicateUsersOption(%q) = %+v, want valid", value, fieldErrors)
+		}
+	}
+
+	fieldErrors := validateDuplicateUsersOption("keep-all")
+	if len(fieldErrors) != 1 || fieldErrors[0].Field != "duplicateUsers" || fieldErrors[0].Code != codeInvalidFormat {
+		t.Errorf("validateDuplicateUsersOption(keep-all) = %+v", fieldErrors)
+	}
+}
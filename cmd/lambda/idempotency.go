@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// idempotencyKeyHeader is the request header carrying the client-provided idempotency key
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks responses replayed from the idempotency store
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength bounds the size of keys accepted from clients
+	maxIdempotencyKeyLength = 255
+	// defaultIdempotencyTTL is how long stored results are replayed when IDEMPOTENCY_TTL is not set
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyReservationTTL bounds how long a key stays reserved by a request that is still running.
+	// It matches Lambda's maximum timeout, so a reservation left by a crashed invocation lapses.
+	idempotencyReservationTTL = 15 * time.Minute
+	// idempotencyRetryAfterSeconds is the Retry-After sent while the first request for a key is running
+	idempotencyRetryAfterSeconds = "2"
+	// memoryIdempotencySweepInterval is how often the in-memory store evicts expired records
+	memoryIdempotencySweepInterval = time.Minute
+
+	codeInvalidIdempotencyKey = "invalid_idempotency_key"
+	codeIdempotencyKeyReused  = "idempotency_key_reused"
+	codeIdempotencyInProgress = "idempotency_request_in_progress"
+)
+
+// errIdempotencyRecordExists is returned by IdempotencyStore.Put when a record for the key is already stored
+var errIdempotencyRecordExists = errors.New("idempotency record already exists")
+
+// IdempotencyRecord is the stored result of the first request made with an idempotency key
+type IdempotencyRecord struct {
+	Key         string            // Client-provided idempotency key
+	RequestHash string            // Hash of the request the result belongs to
+	StatusCode  int               // Status code of the stored response
+	Headers     map[string]string // Headers of the stored response
+	Body        string            // Body of the stored response
+	InProgress  bool              // The first request is still running and there is no response yet
+	ExpiresAt   time.Time         // Time after which the record is no longer replayed
+}
+
+// IdempotencyStore persists request results by idempotency key
+type IdempotencyStore interface {
+	// Get returns the unexpired record for the key, or nil if there is none
+	Get(ctx context.Context, key string) (*IdempotencyRecord, error)
+	// Put stores the record unless one already exists for the key, in which case
+	// errIdempotencyRecordExists is returned. Requests reserve their key with it.
+	Put(ctx context.Context, record IdempotencyRecord) error
+	// Update replaces the record for the key, storing the result of the request that reserved it
+	Update(ctx context.Context, record IdempotencyRecord) error
+	// Delete removes the record for the key, releasing a reservation whose result is not stored
+	Delete(ctx context.Context, key string) error
+}
+
+// Global idempotency configuration, initialized in init()
+var (
+	idempotencyStore IdempotencyStore
+	idempotencyTTL   = defaultIdempotencyTTL
+)
+
+// memoryIdempotencyStore keeps records in memory for the lifetime of the Lambda container or server
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]IdempotencyRecord
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// newMemoryIdempotencyStore creates an empty in-memory idempotency store
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		records: make(map[string]IdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+// Get returns the unexpired record for the key, or nil if there is none
+func (s *memoryIdempotencyStore) Get(_ context.Context, key string) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return nil, nil
+	}
+	if !s.now().Before(record.ExpiresAt) {
+		delete(s.records, key)
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// Put stores the record unless an unexpired one already exists for the key
+func (s *memoryIdempotencyStore) Put(_ context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictExpired(now)
+	if existing, ok := s.records[record.Key]; ok && now.Before(existing.ExpiresAt) {
+		return errIdempotencyRecordExists
+	}
+	s.records[record.Key] = record
+	return nil
+}
+
+// Update replaces the record for the key
+func (s *memoryIdempotencyStore) Update(_ context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[record.Key] = record
+	return nil
+}
+
+// Delete removes the record for the key
+func (s *memoryIdempotencyStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// evictExpired drops expired records at most once per sweep interval, so a long-running server
+// does not keep every key it has seen. The caller must hold s.mu.
+func (s *memoryIdempotencyStore) evictExpired(now time.Time) {
+	if now.Sub(s.lastSweep) < memoryIdempotencySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// dynamoDBAPI is the subset of the DynamoDB client used by the idempotency store
+type dynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// dynamoDBIdempotencyStore keeps records in a DynamoDB table with the partition key "idempotencyKey".
+// The "expiresAt" attribute holds a Unix timestamp and can be used as the table's TTL attribute.
+type dynamoDBIdempotencyStore struct {
+	client    dynamoDBAPI
+	tableName string
+	now       func() time.Time
+}
+
+// newDynamoDBIdempotencyStore creates an idempotency store backed by the given DynamoDB table
+func newDynamoDBIdempotencyStore(client dynamoDBAPI, tableName string) *dynamoDBIdempotencyStore {
+	return &dynamoDBIdempotencyStore{
+		client:    client,
+		tableName: tableName,
+		now:       time.Now,
+	}
+}
+
+// Get returns the unexpired record for the key, or nil if there is none.
+// Expiry is checked here as well because DynamoDB TTL deletes items lazily.
+func (s *dynamoDBIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            map[string]types.AttributeValue{"idempotencyKey": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+
+	record, err := idempotencyRecordFromItem(output.Item)
+	if err != nil {
+		return nil, err
+	}
+	if !s.now().Before(record.ExpiresAt) {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// Put stores the record unless an unexpired one already exists for the key
+func (s *dynamoDBIdempotencyStore) Put(ctx context.Context, record IdempotencyRecord) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.tableName),
+		Item:                idempotencyRecordToItem(record),
+		ConditionExpression: aws.String("attribute_not_exists(idempotencyKey) OR expiresAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(s.now().Unix(), 10)},
+		},
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return errIdempotencyRecordExists
+		}
+		return fmt.Errorf("failed to put idempotency record: %w", err)
+	}
+	return nil
+}
+
+// Update replaces the record for the key
+func (s *dynamoDBIdempotencyStore) Update(ctx context.Context, record IdempotencyRecord) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      idempotencyRecordToItem(record),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update idempotency record: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the record for the key
+func (s *dynamoDBIdempotencyStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       map[string]types.AttributeValue{"idempotencyKey": &types.AttributeValueMemberS{Value: key}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency record: %w", err)
+	}
+	return nil
+}
+
+// idempotencyRecordToItem converts a record into a DynamoDB item
+func idempotencyRecordToItem(record IdempotencyRecord) map[string]types.AttributeValue {
+	headers := make(map[string]types.AttributeValue, len(record.Headers))
+	for name, value := range record.Headers {
+		headers[name] = &types.AttributeValueMemberS{Value: value}
+	}
+
+	return map[string]types.AttributeValue{
+		"idempotencyKey": &types.AttributeValueMemberS{Value: record.Key},
+		"requestHash":    &types.AttributeValueMemberS{Value: record.RequestHash},
+		"statusCode":     &types.AttributeValueMemberN{Value: strconv.Itoa(record.StatusCode)},
+		"headers":        &types.AttributeValueMemberM{Value: headers},
+		"body":           &types.AttributeValueMemberS{Value: record.Body},
+		"inProgress":     &types.AttributeValueMemberBOOL{Value: record.InProgress},
+		"expiresAt":      &types.AttributeValueMemberN{Value: strconv.FormatInt(record.ExpiresAt.Unix(), 10)},
+	}
+}
+
+// idempotencyRecordFromItem converts a DynamoDB item into a record
+func idempotencyRecordFromItem(item map[string]types.AttributeValue) (*IdempotencyRecord, error) {
+	record := &IdempotencyRecord{Headers: map[string]string{}}
+
+	if v, ok := item["idempotencyKey"].(*types.AttributeValueMemberS); ok {
+		record.Key = v.Value
+	}
+	if v, ok := item["requestHash"].(*types.AttributeValueMemberS); ok {
+		record.RequestHash = v.Value
+	}
+	if v, ok := item["body"].(*types.AttributeValueMemberS); ok {
+		record.Body = v.Value
+	}
+	if v, ok := item["inProgress"].(*types.AttributeValueMemberBOOL); ok {
+		record.InProgress = v.Value
+	}
+	if v, ok := item["headers"].(*types.AttributeValueMemberM); ok {
+		for name, value := range v.Value {
+			if s, ok := value.(*types.AttributeValueMemberS); ok {
+				record.Headers[name] = s.Value
+			}
+		}
+	}
+
+	statusCode, ok := item["statusCode"].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, fmt.Errorf("idempotency record '%s' has no status code", record.Key)
+	}
+	code, err := strconv.Atoi(statusCode.Value)
+	if err != nil {
+		return nil, fmt.Errorf("idempotency record '%s' has invalid status code: %w", record.Key, err)
+	}
+	record.StatusCode = code
+
+	expiresAt, ok := item["expiresAt"].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, fmt.Errorf("idempotency record '%s' has no expiry", record.Key)
+	}
+	seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("idempotency record '%s' has invalid expiry: %w", record.Key, err)
+	}
+	record.ExpiresAt = time.Unix(seconds, 0)
+
+	return record, nil
+}
+
+// newIdempotencyStoreFromEnv selects the idempotency store based on IDEMPOTENCY_TABLE_NAME.
+// Without a table, results are only replayed for retries reaching the same warm container.
+func newIdempotencyStoreFromEnv(cfg aws.Config) IdempotencyStore {
+	if tableName := os.Getenv("IDEMPOTENCY_TABLE_NAME"); tableName != "" {
+		slog.Info("Using DynamoDB idempotency store", "table", tableName)
+		return newDynamoDBIdempotencyStore(dynamodb.NewFromConfig(cfg), tableName)
+	}
+	slog.Info("Using in-memory idempotency store")
+	return newMemoryIdempotencyStore()
+}
+
+// idempotencyTTLFromEnv reads IDEMPOTENCY_TTL (a Go duration such as "24h"), falling back to the default
+func idempotencyTTLFromEnv() time.Duration {
+	value := os.Getenv("IDEMPOTENCY_TTL")
+	if value == "" {
+		return defaultIdempotencyTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		slog.Warn("Invalid IDEMPOTENCY_TTL, using default", "value", value, "default", defaultIdempotencyTTL.String())
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// getHeader returns a request header value using a case-insensitive name match
+func getHeader(request events.APIGatewayProxyRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	for key, values := range request.MultiValueHeaders {
+		if strings.EqualFold(key, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// hashIdempotentRequest fingerprints the parts of a request that must match for a result to be replayed.
+// The caller is included so one caller cannot replay the result of another's request.
+func hashIdempotentRequest(request events.APIGatewayProxyRequest) string {
+	caller := callerFromRequest(request)
+	sum := sha256.Sum256([]byte(request.HTTPMethod + "\n" + request.Path + "\n" + caller.ID + "\n" + caller.Email + "\n" + request.Body))
+	return hex.EncodeToString(sum[:])
+}
+
+// isReplayableResponse reports whether a response is final and safe to replay.
+// Rate limiting and server errors are transient, and authorization failures may be lifted
+// by a policy change, so retries must be allowed to run again. Batch results are only final
+// if none of their projects failed for one of those reasons.
+func isReplayableResponse(response events.APIGatewayProxyResponse) bool {
+	statusCode := response.StatusCode
+	if statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests || statusCode == http.StatusForbidden {
+		return false
+	}
+	if statusCode == http.StatusMultiStatus {
+		return !hasRetryableProjectResults(response.Body)
+	}
+	return true
+}
+
+// withIdempotency runs the handler at most once per Idempotency-Key header and replays the stored
+// result verbatim for retries with the same key. The key is reserved before the handler runs, so
+// concurrent retries are told to try again instead of running it a second time. Requests without
+// the header are passed through.
+func withIdempotency(ctx context.Context, request events.APIGatewayProxyRequest, store IdempotencyStore, handler func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)) (events.APIGatewayProxyResponse, error) {
+	key := getHeader(request, idempotencyKeyHeader)
+	if key == "" || store == nil {
+		return handler(ctx, request)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return respondLambdaError(http.StatusBadRequest, codeInvalidIdempotencyKey,
+			fmt.Sprintf("Idempotency-Key must be at most %d characters long", maxIdempotencyKeyLength), nil)
+	}
+
+	requestHash := hashIdempotentRequest(request)
+
+	record, err := store.Get(ctx, key)
+	if err != nil {
+		// Fail open: losing idempotency is preferable to rejecting the request
+		slog.WarnContext(ctx, "Failed to read idempotency record", "idempotencyKey", key, "error", err)
+		return handler(ctx, request)
+	}
+	if record != nil {
+		return respondIdempotencyRecord(ctx, *record, requestHash)
+	}
+
+	// Reserve the key; if another request got there first, answer as if its record had been read
+	reservation := IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		InProgress:  true,
+		ExpiresAt:   time.Now().Add(idempotencyReservationTTL),
+	}
+	if err := store.Put(ctx, reservation); err != nil {
+		if !errors.Is(err, errIdempotencyRecordExists) {
+			slog.WarnContext(ctx, "Failed to reserve idempotency key", "idempotencyKey", key, "error", err)
+			return handler(ctx, request)
+		}
+		record, err := store.Get(ctx, key)
+		if err != nil || record == nil {
+			// The other request's record is unreadable or already gone; the client can simply retry
+			return respondIdempotencyInProgress()
+		}
+		return respondIdempotencyRecord(ctx, *record, requestHash)
+	}
+
+	response, err := handler(ctx, request)
+	if err != nil || !isReplayableResponse(response) {
+		if deleteErr := store.Delete(ctx, key); deleteErr != nil {
+			slog.WarnContext(ctx, "Failed to release idempotency key", "idempotencyKey", key, "error", deleteErr)
+		}
+		return response, err
+	}
+
+	result := IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		StatusCode:  response.StatusCode,
+		Headers:     response.Headers,
+		Body:        response.Body,
+		ExpiresAt:   time.Now().Add(idempotencyTTL),
+	}
+	if err := store.Update(ctx, result); err != nil {
+		slog.WarnContext(ctx, "Failed to store idempotency record", "idempotencyKey", key, "error", err)
+	}
+
+	return response, nil
+}
+
+// respondIdempotencyRecord answers a request whose key already has a record: keys reused for a
+// different request are rejected, running requests are asked to retry and results are replayed
+func respondIdempotencyRecord(ctx context.Context, record IdempotencyRecord, requestHash string) (events.APIGatewayProxyResponse, error) {
+	if record.RequestHash != requestHash {
+		return respondLambdaError(http.StatusUnprocessableEntity, codeIdempotencyKeyReused,
+			"Idempotency-Key was already used for a different request", nil)
+	}
+	if record.InProgress {
+		slog.InfoContext(ctx, "Request with the same idempotency key is still running", "idempotencyKey", record.Key)
+		return respondIdempotencyInProgress()
+	}
+	slog.InfoContext(ctx, "Replaying stored response", "idempotencyKey", record.Key)
+	return replayIdempotencyRecord(record), nil
+}
+
+// respondIdempotencyInProgress asks the client to retry once the first request with its key has finished
+func respondIdempotencyInProgress() (events.APIGatewayProxyResponse, error) {
+	response, _ := respondLambdaError(http.StatusConflict, codeIdempotencyInProgress,
+		"A request with this Idempotency-Key is still being processed; retry later", nil)
+	response.Headers["Retry-After"] = idempotencyRetryAfterSeconds
+	return response, nil
+}
+
+// replayIdempotencyRecord rebuilds the stored response, marking it as replayed
+func replayIdempotencyRecord(record IdempotencyRecord) events.APIGatewayProxyResponse {
+	headers := make(map[string]string, len(record.Headers)+1)
+	for name, value := range record.Headers {
+		headers[name] = value
+	}
+	headers[idempotentReplayedHeader] = "true"
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: record.StatusCode,
+		Headers:    headers,
+		Body:       record.Body,
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// testIdempotencyStore runs the behavior every IdempotencyStore implementation must provide
+func testIdempotencyStore(t *testing.T, store IdempotencyStore, key string) {
+	ctx := context.Background()
+
+	record, err := store.Get(ctx, key)
+	if err != nil || record != nil {
+		t.Fatalf("Get() on empty store = %v, %v, want nil, nil", record, err)
+	}
+
+	stored := IdempotencyRecord{
+		Key:         key,
+		RequestHash: "hash",
+		StatusCode:  http.StatusOK,
+		Headers:     map[string]string{"Content-Type": "application/json"},
+		Body:        `{"success":true}`,
+		ExpiresAt:   time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+	if err := store.Put(ctx, stored); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	record, err = store.Get(ctx, key)
+	if err != nil || record == nil {
+		t.Fatalf("Get() after Put() = %v, %v", record, err)
+	}
+	if record.RequestHash != stored.RequestHash || record.StatusCode != stored.StatusCode ||
+		record.Body != stored.Body || record.Headers["Content-Type"] != "application/json" ||
+		!record.ExpiresAt.Equal(stored.ExpiresAt) {
+		t.Errorf("Get() = %+v, want %+v", record, stored)
+	}
+
+	// The first result wins
+	second := stored
+	second.Body = `{"success":false}`
+	if err := store.Put(ctx, second); !errors.Is(err, errIdempotencyRecordExists) {
+		t.Errorf("Put() for existing key error = %v, want %v", err, errIdempotencyRecordExists)
+	}
+
+	// Expired records are not returned and can be replaced
+	expiredKey := key + "-expired"
+	expired := stored
+	expired.Key = expiredKey
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := store.Put(ctx, expired); err != nil {
+		t.Fatalf("Put() expired record error = %v", err)
+	}
+	if record, err := store.Get(ctx, expiredKey); err != nil || record != nil {
+		t.Errorf("Get() for expired record = %v, %v, want nil, nil", record, err)
+	}
+	expired.ExpiresAt = time.Now().Add(time.Hour)
+	if err := store.Put(ctx, expired); err != nil {
+		t.Errorf("Put() over expired record error = %v", err)
+	}
+
+	// Reservations are replaced by the result, or deleted
+	reservedKey := key + "-reserved"
+	reservation := IdempotencyRecord{Key: reservedKey, RequestHash: "hash", InProgress: true, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := store.Put(ctx, reservation); err != nil {
+		t.Fatalf("Put() reservation error = %v", err)
+	}
+	if record, err := store.Get(ctx, reservedKey); err != nil || record == nil || !record.InProgress {
+		t.Errorf("Get() for reservation = %+v, %v, want an in-progress record", record, err)
+	}
+	result := stored
+	result.Key = reservedKey
+	if err := store.Update(ctx, result); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if record, err := store.Get(ctx, reservedKey); err != nil || record == nil || record.InProgress || record.Body != stored.Body {
+		t.Errorf("Get() after Update() = %+v, %v, want the result", record, err)
+	}
+	if err := store.Delete(ctx, reservedKey); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if record, err := store.Get(ctx, reservedKey); err != nil || record != nil {
+		t.Errorf("Get() after Delete() = %v, %v, want nil, nil", record, err)
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	testIdempotencyStore(t, newMemoryIdempotencyStore(), "memory-key")
+}
+
+func TestMemoryIdempotencyStoreEvictsExpiredRecords(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryIdempotencyStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		store.Put(ctx, IdempotencyRecord{Key: fmt.Sprintf("key-%d", i), ExpiresAt: now.Add(time.Minute)})
+	}
+
+	// Records that are never read again are still dropped once expired
+	now = now.Add(2 * time.Minute)
+	store.Put(ctx, IdempotencyRecord{Key: "fresh", ExpiresAt: now.Add(time.Minute)})
+	if len(store.records) != 1 {
+		t.Errorf("store holds %d records after expiry, want 1", len(store.records))
+	}
+}
+
+// racingIdempotencyStore hides the record from the first Get, as if another request stored it
+// between that Get and the reservation
+type racingIdempotencyStore struct {
+	*memoryIdempotencyStore
+	hidden bool
+}
+
+func (s *racingIdempotencyStore) Get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	if !s.hidden {
+		s.hidden = true
+		return nil, nil
+	}
+	return s.memoryIdempotencyStore.Get(ctx, key)
+}
+
+// TestDynamoDBIdempotencyStore runs against DynamoDB Local, e.g.:
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	DYNAMODB_LOCAL_ENDPOINT=http://localhost:8000 go test -run TestDynamoDBIdempotencyStore
+func TestDynamoDBIdempotencyStore(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT not set")
+	}
+
+	ctx := context.Background()
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("local", "local", ""),
+	})
+
+	tableName := fmt.Sprintf("idempotency-test-%d", time.Now().UnixNano())
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("idempotencyKey"), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String("idempotencyKey"), KeyType: types.KeyTypeHash}},
+		BillingMode:          types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	defer client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+
+	testIdempotencyStore(t, newDynamoDBIdempotencyStore(client, tableName), "dynamodb-key")
+}
+
+func TestGetHeader(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Headers:           map[string]string{"idempotency-key": "abc"},
+		MultiValueHeaders: map[string][]string{"X-Other": {"first", "second"}},
+	}
+
+	if value := getHeader(request, "Idempotency-Key"); value != "abc" {
+		t.Errorf("getHeader(Idempotency-Key) = %q, want %q", value, "abc")
+	}
+	if value := getHeader(request, "x-other"); value != "first" {
+		t.Errorf("getHeader(x-other) = %q, want %q", value, "first")
+	}
+	if value := getHeader(request, "Missing"); value != "" {
+		t.Errorf("getHeader(Missing) = %q, want empty", value)
+	}
+}
+
+func TestWithIdempotency(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	calls := 0
+	statusCode := http.StatusOK
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		calls++
+		return respondLambdaWithStatus(statusCode, statusCode == http.StatusOK, fmt.Sprintf("call %d", calls))
+	}
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/create-project",
+		Headers:    map[string]string{"Idempotency-Key": "key-1"},
+		Body:       `{"appID": "my-project"}`,
+	}
+
+	// First request runs the handler
+	first, err := withIdempotency(context.Background(), request, store, handler)
+	if err != nil || calls != 1 {
+		t.Fatalf("withIdempotency() first call = %v, calls %d", err, calls)
+	}
+
+	// Retry replays the first result verbatim
+	retry, err := withIdempotency(context.Background(), request, store, handler)
+	if err != nil {
+		t.Errorf("withIdempotency() retry error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("withIdempotency() retry ran the handler again")
+	}
+	if retry.StatusCode != first.StatusCode || retry.Body != first.Body || retry.Headers[idempotentReplayedHeader] != "true" {
+		t.Errorf("withIdempotency() retry = %+v, want replay of %+v", retry, first)
+	}
+
+	// Reusing the key for a different request is rejected
+	other := request
+	other.Body = `{"appID": "other-project"}`
+	response, _ := withIdempotency(context.Background(), other, store, handler)
+	if response.StatusCode != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("withIdempotency() reused key status = %d, calls %d", response.StatusCode, calls)
+	}
+
+	// Another caller cannot replay the result with the same key
+	otherCaller := request
+	otherCaller.RequestContext.Identity.UserArn = "arn:aws:iam::123456789012:user/someone-else"
+	response, _ = withIdempotency(context.Background(), otherCaller, store, handler)
+	if response.StatusCode != http.StatusUnprocessableEntity || calls != 1 {
+		t.Errorf("withIdempotency() other caller status = %d, calls %d", response.StatusCode, calls)
+	}
+
+	// Transient failures are not stored
+	statusCode = http.StatusServiceUnavailable
+	request.Headers = map[string]string{"Idempotency-Key": "key-2"}
+	withIdempotency(context.Background(), request, store, handler)
+	withIdempotency(context.Background(), request, store, handler)
+	if calls != 3 {
+		t.Errorf("withIdempotency() transient failure calls = %d, want 3", calls)
+	}
+
+	// Requests without a key always run the handler
+	request.Headers = nil
+	withIdempotency(context.Background(), request, store, handler)
+	if calls != 4 {
+		t.Errorf("withIdempotency() without key calls = %d, want 4", calls)
+	}
+}
+
+func TestIsReplayableResponse(t *testing.T) {
+	batch := func(results ...ProjectResult) events.APIGatewayProxyResponse {
+		response, _ := respondProjectResults(results)
+		return response
+	}
+	created := ProjectResult{AppID: "payments", Status: createStatusCreated}
+
+	tests := []struct {
+		name       string
+		response   events.APIGatewayProxyResponse
+		replayable bool
+	}{
+		{"success", events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, true},
+		{"conflict", events.APIGatewayProxyResponse{StatusCode: http.StatusConflict}, true},
+		{"forbidden", events.APIGatewayProxyResponse{StatusCode: http.StatusForbidden}, false},
+		{"rate limited", events.APIGatewayProxyResponse{StatusCode: http.StatusTooManyRequests}, false},
+		{"unavailable", events.APIGatewayProxyResponse{StatusCode: http.StatusServiceUnavailable}, false},
+		{"batch with final failures", batch(created,
+			ProjectResult{AppID: "billing", Status: createStatusConflict, Code: codeConflict},
+			ProjectResult{AppID: "checkout", Status: createStatusLookupFailed, Code: codeUserNotFound}), true},
+		{"batch with Nobl9 unavailable", batch(created, ProjectResult{AppID: "billing", Status: createStatusFailed, Code: codeNobl9Unavailable}), false},
+		{"batch with rate limiting", batch(created, ProjectResult{AppID: "billing", Status: createStatusFailed, Code: codeNobl9RateLimited}), false},
+		{"batch with failed user lookup", batch(created, ProjectResult{AppID: "billing", Status: createStatusLookupFailed, Code: codeNobl9Unavailable}), false},
+		{"batch with denied project", batch(created, ProjectResult{AppID: "billing", Status: createStatusForbidden, Code: codeForbidden}), false},
+		{"batch with unreadable body", events.APIGatewayProxyResponse{StatusCode: http.StatusMultiStatus, Body: "not json"}, false},
+	}
+	for _, tt := range tests {
+		if replayable := isReplayableResponse(tt.response); replayable != tt.replayable {
+			t.Errorf("isReplayableResponse(%s) = %v, want %v", tt.name, replayable, tt.replayable)
+		}
+	}
+}
+
+func TestWithIdempotencyConcurrentRequests(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/create-project",
+		Headers:    map[string]string{"Idempotency-Key": "key-1"},
+		Body:       `{"appID": "my-project"}`,
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	slow := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		calls++
+		close(started)
+		<-release
+		return respondLambdaWithStatus(http.StatusOK, true, "created")
+	}
+
+	done := make(chan events.APIGatewayProxyResponse)
+	go func() {
+		response, _ := withIdempotency(context.Background(), request, store, slow)
+		done <- response
+	}()
+	<-started
+
+	// A retry while the first request is running does not run the handler
+	unexpected := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		t.Error("handler ran while the first request held the key")
+		return respondLambdaWithStatus(http.StatusOK, true, "duplicate")
+	}
+	response, err := withIdempotency(context.Background(), request, store, unexpected)
+	if err != nil || response.StatusCode != http.StatusConflict || response.Headers["Retry-After"] == "" {
+		t.Errorf("withIdempotency() during first request = %d %v, headers %v", response.StatusCode, err, response.Headers)
+	}
+
+	close(release)
+	first := <-done
+
+	// Once it has finished, retries replay its result
+	response, _ = withIdempotency(context.Background(), request, store, unexpected)
+	if response.StatusCode != http.StatusOK || response.Body != first.Body || response.Headers[idempotentReplayedHeader] != "true" {
+		t.Errorf("withIdempotency() after first request = %+v, want replay of %+v", response, first)
+	}
+	if calls != 1 {
+		t.Errorf("handler calls = %d, want 1", calls)
+	}
+}
+
+func TestWithIdempotencyReplaysRecordStoredConcurrently(t *testing.T) {
+	store := &racingIdempotencyStore{memoryIdempotencyStore: newMemoryIdempotencyStore()}
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/create-project",
+		Headers:    map[string]string{"Idempotency-Key": "key-1"},
+		Body:       `{"appID": "my-project"}`,
+	}
+	store.Put(context.Background(), IdempotencyRecord{
+		Key:         "key-1",
+		RequestHash: hashIdempotentRequest(request),
+		StatusCode:  http.StatusOK,
+		Body:        "stored",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		t.Error("handler ran although the key was already taken")
+		return respondLambdaWithStatus(http.StatusOK, true, "duplicate")
+	}
+	response, err := withIdempotency(context.Background(), request, store, handler)
+	if err != nil || response.Body != "stored" || response.Headers[idempotentReplayedHeader] != "true" {
+		t.Errorf("withIdempotency() = %+v, %v, want the stored record replayed", response, err)
+	}
+}
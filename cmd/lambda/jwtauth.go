@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	codeUnauthorized        = "unauthorized"
+	defaultJWKSCacheTTL     = time.Hour
+	defaultJWTClockSkew     = time.Minute
+	oidcDiscoveryPath       = "/.well-known/openid-configuration"
+	oidcDiscoveryTimeout    = 10 * time.Second
+	maxOIDCDiscoveryDocSize = 1 << 20
+)
+
+// jwtSigningMethods are the asymmetric algorithms accepted for bearer tokens. HMAC and "none" are
+// never accepted, so a token cannot be signed with the public key or not at all.
+var jwtSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// jwtAuth validates bearer tokens when JWT authentication is configured, or is nil otherwise
+var jwtAuth *jwtAuthenticator
+
+// JWTAuthConfig configures bearer token authentication
+type JWTAuthConfig struct {
+	Issuer       string        // Expected iss claim
+	Audiences    []string      // Accepted aud values; tokens must be issued for at least one
+	JWKSURL      string        // JWKS endpoint; discovered from the issuer if unset
+	JWKSFile     string        // Local JWKS file used instead of fetching keys, e.g. for tests
+	JWKSCacheTTL time.Duration // How often fetched keys are refreshed
+	ClockSkew    time.Duration // Leeway allowed when checking exp, nbf and iat
+}
+
+// jwtAuthConfigFromEnv reads the bearer token settings from the environment. It returns nil if JWT_ISSUER is unset.
+func jwtAuthConfigFromEnv() (*JWTAuthConfig, error) {
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	config := &JWTAuthConfig{
+		Issuer:       issuer,
+		JWKSURL:      os.Getenv("JWT_JWKS_URL"),
+		JWKSFile:     os.Getenv("JWT_JWKS_FILE"),
+		JWKSCacheTTL: defaultJWKSCacheTTL,
+		ClockSkew:    defaultJWTClockSkew,
+	}
+	for _, audience := range strings.Split(os.Getenv("JWT_AUDIENCE"), ",") {
+		if audience = strings.TrimSpace(audience); audience != "" {
+			config.Audiences = append(config.Audiences, audience)
+		}
+	}
+	if len(config.Audiences) == 0 {
+		return nil, fmt.Errorf("JWT_AUDIENCE must be set when JWT_ISSUER is set")
+	}
+
+	for name, target := range map[string]*time.Duration{"JWT_JWKS_CACHE_TTL": &config.JWKSCacheTTL, "JWT_CLOCK_SKEW": &config.ClockSkew} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid %s '%s': must be a non-negative duration such as 1h", name, value)
+		}
+		*target = duration
+	}
+	return config, nil
+}
+
+// jwtAuthenticator validates bearer tokens against the configured issuer, audiences and keys
+type jwtAuthenticator struct {
+	config  JWTAuthConfig
+	keyfunc jwt.Keyfunc
+}
+
+// newJWTAuthenticator loads the signing keys from the JWKS file, or sets up a cached JWKS client that refreshes
+// keys every JWKSCacheTTL and when a token names an unknown key. The refresh stops when ctx is done.
+func newJWTAuthenticator(ctx context.Context, config JWTAuthConfig) (*jwtAuthenticator, error) {
+	if config.JWKSFile != "" {
+		data, err := os.ReadFile(config.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		keys, err := keyfunc.NewJWKSetJSON(json.RawMessage(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS file %s: %w", config.JWKSFile, err)
+		}
+		slog.InfoContext(ctx, "Validating bearer tokens with local keys", "issuer", config.Issuer, "jwksFile", config.JWKSFile)
+		return &jwtAuthenticator{config: config, keyfunc: keys.Keyfunc}, nil
+	}
+
+	jwksURL := config.JWKSURL
+	if jwksURL == "" {
+		discovered, err := discoverJWKSURL(ctx, config.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		jwksURL = discovered
+	}
+
+	keys, err := keyfunc.NewDefaultOverrideCtx(ctx, []string{jwksURL}, keyfunc.Override{RefreshInterval: config.JWKSCacheTTL})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up JWKS client: %w", err)
+	}
+	slog.InfoContext(ctx, "Validating bearer tokens", "issuer", config.Issuer, "jwksUrl", jwksURL)
+	return &jwtAuthenticator{config: config, keyfunc: keys.Keyfunc}, nil
+}
+
+// newJWTAuthenticatorFromEnv sets up bearer token authentication if JWT_ISSUER is set, and returns nil otherwise
+func newJWTAuthenticatorFromEnv(ctx context.Context) (*jwtAuthenticator, error) {
+	config, err := jwtAuthConfigFromEnv()
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return newJWTAuthenticator(ctx, *config)
+}
+
+// discoverJWKSURL reads the JWKS endpoint from the issuer's OpenID Connect discovery document
+func discoverJWKSURL(ctx context.Context, issuer string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, oidcDiscoveryTimeout)
+	defer cancel()
+
+	discoveryURL := strings.TrimSuffix(issuer, "/") + oidcDiscoveryPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid JWT_ISSUER '%s': %w", issuer, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch OpenID configuration: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch OpenID configuration from %s: status %d", discoveryURL, resp.StatusCode)
+	}
+
+	var document struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOIDCDiscoveryDocSize)).Decode(&document); err != nil {
+		return "", fmt.Errorf("invalid OpenID configuration from %s: %w", discoveryURL, err)
+	}
+	if document.Issuer != issuer {
+		return "", fmt.Errorf("OpenID configuration from %s is for issuer '%s', not '%s'", discoveryURL, document.Issuer, issuer)
+	}
+	if document.JWKSURI == "" {
+		return "", fmt.Errorf("OpenID configuration from %s has no jwks_uri", discoveryURL)
+	}
+	return document.JWKSURI, nil
+}
+
+// Authenticate validates the bearer token in an Authorization header value and returns its claims.
+// The token must be signed by a known key and be issued by the issuer for one of the audiences, and must not be expired.
+func (a *jwtAuthenticator) Authenticate(authorization string) (jwt.MapClaims, error) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, errors.New("missing bearer token")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, a.keyfunc,
+		jwt.WithValidMethods(jwtSigningMethods),
+		jwt.WithIssuer(a.config.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(a.config.ClockSkew),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	audiences, err := claims.GetAudience()
+	if err != nil {
+		return nil, err
+	}
+	for _, audience := range audiences {
+		if containsString(a.config.Audiences, audience) {
+			return claims, nil
+		}
+	}
+	return nil, fmt.Errorf("token was not issued for this API")
+}
+
+// requiresAuthentication reports whether a request needs a bearer token. Browsers send CORS preflight
+// requests without credentials, and load balancers probe the health check anonymously.
+func requiresAuthentication(request events.APIGatewayProxyRequest) bool {
+	return request.HTTPMethod != "OPTIONS" && request.Path != "/health"
+}
+
+// authenticateRequest validates the request's bearer token and exposes the token's claims the way API Gateway
+// exposes authorizer claims, so authorization and audit logging see the token's caller. Requests are returned
+// unchanged when auth is nil or they need no token.
+func authenticateRequest(auth *jwtAuthenticator, request events.APIGatewayProxyRequest) (events.APIGatewayProxyRequest, error) {
+	if auth == nil || !requiresAuthentication(request) {
+		return request, nil
+	}
+
+	claims, err := auth.Authenticate(getHeader(request, "Authorization"))
+	if err != nil {
+		return request, err
+	}
+
+	// Only the validated token speaks for the caller, whatever authorizer context the request carried
+	authorizer := make(map[string]interface{}, len(request.RequestContext.Authorizer)+1)
+	for key, value := range request.RequestContext.Authorizer {
+		authorizer[key] = value
+	}
+	authorizer["claims"] = map[string]interface{}(claims)
+	request.RequestContext.Authorizer = authorizer
+	return request, nil
+}
+
+// respondUnauthorized rejects a request whose bearer token is missing or invalid
+func respondUnauthorized(err error) (events.APIGatewayProxyResponse, error) {
+	response, _ := respondLambdaError(http.StatusUnauthorized, codeUnauthorized, "Invalid bearer token: "+err.Error(), nil)
+	response.Headers["WWW-Authenticate"] = `Bearer error="invalid_token"`
+	return response, nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testJWTIssuer   = "https://login.example.com/oauth2/default"
+	testJWTAudience = "nobl9-wizard"
+	testJWTKeyID    = "test-key"
+)
+
+// newTestSigningKey generates an RSA key for signing test tokens
+func newTestSigningKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	return key
+}
+
+// testJWKS encodes the public key as a JSON Web Key Set
+func testJWKS(key *rsa.PrivateKey) []byte {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","use":"sig","alg":"RS256","kid":%q,"n":%q,"e":%q}]}`,
+		testJWTKeyID, encode(key.N.Bytes()), encode(big.NewInt(int64(key.E)).Bytes()))
+	return []byte(jwks)
+}
+
+// signTestToken signs claims with the key, filling in a valid issuer, audience and lifetime unless overridden
+func signTestToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.MapClaims{
+		"iss": testJWTIssuer,
+		"aud": testJWTAudience,
+		"sub": "00u1abcd",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(token, name)
+			continue
+		}
+		token[name] = value
+	}
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodRS256, token)
+	unsigned.Header["kid"] = testJWTKeyID
+	signed, err := unsigned.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+// newTestJWTAuthenticator validates tokens signed by the key, loading it from a local JWKS file
+func newTestJWTAuthenticator(t *testing.T, key *rsa.PrivateKey) *jwtAuthenticator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, testJWKS(key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newJWTAuthenticator(context.Background(), JWTAuthConfig{
+		Issuer:    testJWTIssuer,
+		Audiences: []string{testJWTAudience},
+		JWKSFile:  path,
+		ClockSkew: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("newJWTAuthenticator() error = %v", err)
+	}
+	return auth
+}
+
+// useJWTAuth makes the authenticator the package authenticator for the rest of the test
+func useJWTAuth(t *testing.T, auth *jwtAuthenticator) {
+	t.Helper()
+	previous := jwtAuth
+	jwtAuth = auth
+	t.Cleanup(func() { jwtAuth = previous })
+}
+
+func TestJWTAuthConfigFromEnv(t *testing.T) {
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_AUDIENCE", "")
+	t.Setenv("JWT_JWKS_CACHE_TTL", "")
+	t.Setenv("JWT_CLOCK_SKEW", "")
+
+	if config, err := jwtAuthConfigFromEnv(); config != nil || err != nil {
+		t.Fatalf("jwtAuthConfigFromEnv() without an issuer = %+v, %v", config, err)
+	}
+
+	t.Setenv("JWT_ISSUER", testJWTIssuer)
+	if _, err := jwtAuthConfigFromEnv(); err == nil {
+		t.Error("jwtAuthConfigFromEnv() without an audience succeeded")
+	}
+
+	t.Setenv("JWT_AUDIENCE", "nobl9-wizard, portal")
+	t.Setenv("JWT_JWKS_CACHE_TTL", "10m")
+	config, err := jwtAuthConfigFromEnv()
+	if err != nil {
+		t.Fatalf("jwtAuthConfigFromEnv() error = %v", err)
+	}
+	if len(config.Audiences) != 2 || config.Audiences[1] != "portal" || config.JWKSCacheTTL != 10*time.Minute || config.ClockSkew != defaultJWTClockSkew {
+		t.Errorf("jwtAuthConfigFromEnv() = %+v", config)
+	}
+
+	t.Setenv("JWT_CLOCK_SKEW", "soon")
+	if _, err := jwtAuthConfigFromEnv(); err == nil {
+		t.Error("jwtAuthConfigFromEnv() with an invalid clock skew succeeded")
+	}
+}
+
+func TestJWTAuthenticatorAuthenticate(t *testing.T) {
+	key := newTestSigningKey(t)
+	auth := newTestJWTAuthenticator(t, key)
+
+	// Tokens signed with the none algorithm carry no signature at all
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"iss": testJWTIssuer, "aud": testJWTAudience, "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		reason        string
+	}{
+		{"valid", "Bearer " + signTestToken(t, key, nil), ""},
+		{"lowercase scheme", "bearer " + signTestToken(t, key, nil), ""},
+		{"one of several audiences", "Bearer " + signTestToken(t, key, jwt.MapClaims{"aud": []string{"other", testJWTAudience}}), ""},
+		{"missing", "", "missing bearer token"},
+		{"basic auth", "Basic dXNlcjpwYXNz", "missing bearer token"},
+		{"expired", "Bearer " + signTestToken(t, key, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), "expired"},
+		{"no expiry", "Bearer " + signTestToken(t, key, jwt.MapClaims{"exp": nil}), "exp claim is required"},
+		{"other issuer", "Bearer " + signTestToken(t, key, jwt.MapClaims{"iss": "https://evil.example.com"}), "issuer"},
+		{"other audience", "Bearer " + signTestToken(t, key, jwt.MapClaims{"aud": "other"}), "not issued for this API"},
+		{"unknown key", "Bearer " + signTestToken(t, newTestSigningKey(t), nil), "signature is invalid"},
+		{"unsigned", "Bearer " + unsigned, "signing method none is invalid"},
+		{"malformed", "Bearer not-a-token", "malformed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := auth.Authenticate(tt.authorization)
+			if tt.reason == "" {
+				if err != nil || claims["sub"] != "00u1abcd" {
+					t.Errorf("Authenticate() = %v, %v, want the token's claims", claims, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("Authenticate() error = %v, want it to contain %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestHandleRequestJWTAuth(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+	key := newTestSigningKey(t)
+	useJWTAuth(t, newTestJWTAuthenticator(t, key))
+	useAuthzPolicy(t, testAuthzPolicy)
+	captureMetrics(t)
+	buf := captureLogs(t, slog.LevelInfo)
+
+	createProject := func(authorization string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod: "POST",
+			Path:       "/api/create-project",
+			Headers:    map[string]string{"Authorization": authorization},
+			Body:       `{"appID":"billing-api","userGroups":[{"userIds":"bob@example.com","role":"project-viewer"}]}`,
+			RequestContext: events.APIGatewayProxyRequestContext{
+				RequestID: "req-jwt",
+				// Claims not backed by a token must not be trusted
+				Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "forged", "groups": "payments-admins"}},
+			},
+		}
+	}
+
+	// Requests without a valid token are rejected before routing
+	response, err := handleRequest(context.Background(), createProject(""))
+	if err != nil || response.StatusCode != http.StatusUnauthorized || response.Headers["WWW-Authenticate"] == "" {
+		t.Fatalf("handleRequest() without a token = %d %v, headers %v", response.StatusCode, err, response.Headers)
+	}
+	var body Response
+	if json.Unmarshal([]byte(response.Body), &body) != nil || body.Code != codeUnauthorized {
+		t.Errorf("response body = %s, want code %q", response.Body, codeUnauthorized)
+	}
+
+	// The token's claims reach the authorization policy: payments admins may not create billing- projects
+	token := signTestToken(t, key, jwt.MapClaims{"email": "alice@example.com", "groups": []string{"payments-admins"}})
+	response, err = handleRequest(context.Background(), createProject("Bearer "+token))
+	if err != nil || response.StatusCode != http.StatusForbidden || !strings.Contains(response.Body, "payments-") {
+		t.Fatalf("handleRequest() with a token = %d %v: %s", response.StatusCode, err, response.Body)
+	}
+
+	// Audit logs name the token's caller, never the token itself
+	if strings.Contains(buf.String(), token) {
+		t.Error("logs contain the bearer token")
+	}
+	lines := decodeLogLines(t, buf)
+	completed := lines[len(lines)-1]
+	if completed["msg"] != "Request completed" || completed["caller"] != hashEmail("alice@example.com") {
+		t.Errorf("last log line = %v, want the authenticated caller", completed)
+	}
+
+	// CORS preflight requests carry no credentials
+	response, err = handleRequest(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "OPTIONS", Path: "/api/create-project"})
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Errorf("handleRequest() preflight = %d, %v", response.StatusCode, err)
+	}
+}
+
+func TestJWTAuthenticatorDiscovery(t *testing.T) {
+	key := newTestSigningKey(t)
+	var jwksRequests int
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc(oidcDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer":%q,"jwks_uri":%q}`, server.URL, server.URL+"/keys")
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		jwksRequests++
+		w.Write(testJWKS(key))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auth, err := newJWTAuthenticator(ctx, JWTAuthConfig{
+		Issuer:       server.URL,
+		Audiences:    []string{testJWTAudience},
+		JWKSCacheTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("newJWTAuthenticator() error = %v", err)
+	}
+
+	// Keys are fetched once and reused
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Authenticate("Bearer " + signTestToken(t, key, jwt.MapClaims{"iss": server.URL})); err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+	}
+	if jwksRequests != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", jwksRequests)
+	}
+
+	// A discovery document for another issuer is rejected
+	if _, err := discoverJWKSURL(ctx, server.URL+"/other"); err == nil {
+		t.Error("discoverJWKSURL() for a different issuer succeeded")
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// Values of LOG_PII, controlling how emails are written to logs
+const (
+	piiModeHash   = "hash"   // Replace emails with a short stable hash, so lines about the same user can be correlated (default)
+	piiModeRedact = "redact" // Replace emails with a fixed placeholder
+	piiModeOff    = "off"    // Log emails as they are
+)
+
+// redactedValue replaces secrets in logs
+const redactedValue = "[REDACTED]"
+
+var (
+	// logEmailRegex finds email addresses anywhere in a log message or attribute, including URL-encoded ones
+	logEmailRegex = regexp.MustCompile(`[A-Za-z0-9._%+\-]+(?:@|%40)[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+	// logSecretRegex finds bearer tokens and JWTs in log messages
+	logSecretRegex = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*|eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+
+	// secretAttrKeys are attribute keys whose values are never logged
+	secretAttrKeys = []string{"secret", "password", "token", "authorization", "credential", "apikey", "api_key"}
+)
+
+// logAttrsKey is the context key for attributes added to every log line of a request
+type logAttrsKey struct{}
+
+// withLogAttrs returns a context whose log lines carry the given attributes in addition to any already set.
+// An attribute replaces one with the same key, so nested handlers can set the project again.
+func withLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	for _, attr := range existing {
+		if !hasLogAttr(attrs, attr.Key) {
+			combined = append(combined, attr)
+		}
+	}
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, logAttrsKey{}, combined)
+}
+
+// hasLogAttr reports whether attrs contains an attribute with the key
+func hasLogAttr(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// withProjectLogAttr returns a context whose log lines name the project being worked on
+func withProjectLogAttr(ctx context.Context, projectName string) context.Context {
+	return withLogAttrs(ctx, slog.String("project", projectName))
+}
+
+// redactingHandler adds request attributes from the context to every record and removes emails
+// and secrets from messages and attributes before passing them on
+type redactingHandler struct {
+	next    slog.Handler
+	piiMode string
+}
+
+// Enabled reports whether the wrapped handler logs records at the level
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the record and adds the request attributes stored in the context, and the current trace
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, h.redactString(record.Message), record.PC)
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		for _, attr := range attrs {
+			redacted.AddAttrs(h.redactAttr(attr))
+		}
+	}
+	redacted.AddAttrs(traceLogAttrs(ctx)...)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a handler that redacts and adds the given attributes
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr(attr)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redacted), piiMode: h.piiMode}
+}
+
+// WithGroup returns a handler that nests attributes in the group
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), piiMode: h.piiMode}
+}
+
+// redactAttr removes secrets and emails from an attribute, including nested groups
+func (h *redactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+
+	if isSecretAttrKey(attr.Key) {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redactString(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]any, len(group))
+		for i, nested := range group {
+			redacted[i] = h.redactAttr(nested)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		switch value := attr.Value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, h.redactString(value.Error()))
+		case []string:
+			redacted := make([]string, len(value))
+			for i, s := range value {
+				redacted[i] = h.redactString(s)
+			}
+			return slog.Any(attr.Key, redacted)
+		}
+	}
+	return attr
+}
+
+// redactString removes bearer tokens and JWTs from a string and hashes or hides emails, depending on the PII mode
+func (h *redactingHandler) redactString(value string) string {
+	value = logSecretRegex.ReplaceAllStringFunc(value, func(match string) string {
+		if prefix := logSecretRegex.FindStringSubmatch(match)[1]; prefix != "" {
+			return prefix + redactedValue
+		}
+		return redactedValue
+	})
+
+	switch h.piiMode {
+	case piiModeOff:
+		return value
+	case piiModeRedact:
+		return logEmailRegex.ReplaceAllString(value, "[email]")
+	default:
+		return logEmailRegex.ReplaceAllStringFunc(value, hashEmail)
+	}
+}
+
+// hashEmail replaces an email with a short hash of it, so the same address always logs the same way,
+// whether or not it was URL-encoded
+func hashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ReplaceAll(strings.ToLower(email), "%40", "@")))
+	return "[email:" + hex.EncodeToString(sum[:])[:12] + "]"
+}
+
+// isSecretAttrKey reports whether an attribute holds a secret, based on its key
+func isSecretAttrKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretAttrKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// newLogger creates a JSON logger writing to w at the given level, redacting PII according to piiMode
+func newLogger(w io.Writer, level slog.Leveler, piiMode string) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(&redactingHandler{next: handler, piiMode: piiMode})
+}
+
+// logLevelFromEnv reads the minimum log level from LOG_LEVEL: debug, info (default), warn or error
+func logLevelFromEnv() slog.Level {
+	value := os.Getenv("LOG_LEVEL")
+	if value == "" {
+		return slog.LevelInfo
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		slog.Warn("Invalid LOG_LEVEL, using default", "value", value, "default", slog.LevelInfo.String())
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// piiModeFromEnv reads how emails are logged from LOG_PII: hash (default), redact or off
+func piiModeFromEnv() string {
+	value := strings.ToLower(os.Getenv("LOG_PII"))
+	switch value {
+	case "":
+		return piiModeHash
+	case piiModeHash, piiModeRedact, piiModeOff:
+		return value
+	default:
+		slog.Warn("Invalid LOG_PII, using default", "value", value, "default", piiModeHash)
+		return piiModeHash
+	}
+}
+
+// setupLogging makes the structured logger the default for slog and the standard log package.
+// Logs go to stderr, so they never mix with CLI output.
+func setupLogging() {
+	slog.SetDefault(newLogger(os.Stderr, logLevelFromEnv(), piiModeFromEnv()))
+}
+
+// callerIdentity names who sent a request, from authorizer claims or the IAM identity, if known
+func callerIdentity(request events.APIGatewayProxyRequest) string {
+	if claims, ok := request.RequestContext.Authorizer["claims"].(map[string]interface{}); ok {
+		for _, claim := range []string{"email", "cognito:username", "sub"} {
+			if value, ok := claims[claim].(string); ok && value != "" {
+				return value
+			}
+		}
+	}
+	if arn := request.RequestContext.Identity.UserArn; arn != "" {
+		return arn
+	}
+	return request.RequestContext.Identity.Caller
+}
+
+// requestLogContext returns a context whose log lines carry the request ID, caller identity and route.
+// The route is the path template rather than the path, which can hold URL-encoded emails the redaction would miss.
+func requestLogContext(ctx context.Context, request events.APIGatewayProxyRequest) context.Context {
+	attrs := []slog.Attr{
+		slog.String("requestId", request.RequestContext.RequestID),
+		slog.String("method", request.HTTPMethod),
+		slog.String("route", metricsRoute(request.Path)),
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		attrs = append(attrs, slog.String("awsRequestId", lc.AwsRequestID))
+	}
+	if caller := callerIdentity(request); caller != "" {
+		attrs = append(attrs, slog.String("caller", caller))
+	}
+	return withLogAttrs(ctx, attrs...)
+}
+
+// logResponse logs the outcome of a request, with the error code and message of failed responses
+func logResponse(ctx context.Context, response events.APIGatewayProxyResponse, err error, duration time.Duration) {
+	attrs := []any{
+		slog.Int("status", response.StatusCode),
+		slog.Int64("durationMs", duration.Milliseconds()),
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Request failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+
+	var body Response
+	if json.Unmarshal([]byte(response.Body), &body) == nil && !body.Success && body.Message != "" {
+		attrs = append(attrs, slog.String("code", body.Code), slog.String("message", body.Message))
+	}
+
+	switch {
+	case response.StatusCode >= 500:
+		slog.ErrorContext(ctx, "Request completed", attrs...)
+	case response.StatusCode >= 400:
+		slog.WarnContext(ctx, "Request completed", attrs...)
+	default:
+		slog.InfoContext(ctx, "Request completed", attrs...)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// decodeLogLines parses the JSON log lines written to buf
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// captureLogs makes a buffered logger the default for the rest of the test
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(newLogger(&buf, level, piiModeHash))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestLoggerRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, slog.LevelInfo, piiModeHash)
+
+	ctx := withLogAttrs(context.Background(), slog.String("requestId", "req-1"), slog.String("caller", "Alice@Example.com"))
+	ctx = withProjectLogAttr(ctx, "payments")
+	logger.InfoContext(ctx, "Looking up alice@example.com",
+		"clientSecret", "s3cr3t",
+		"authorization", "Bearer abc.def.ghi",
+		"error", errors.New("request failed with Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig"),
+		"suggestions", []string{"bob@example.com"},
+		"userIdentifier", "alice%40example.com",
+		slog.Group("user", slog.String("email", "carol@example.com")),
+	)
+
+	lines := decodeLogLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("logged %d lines, want 1", len(lines))
+	}
+	line := lines[0]
+
+	hashed := hashEmail("alice@example.com")
+	if line["msg"] != "Looking up "+hashed {
+		t.Errorf("msg = %v, want the email hashed", line["msg"])
+	}
+	if line["caller"] != hashed {
+		t.Errorf("caller = %v, want %s (hashes ignore case)", line["caller"], hashed)
+	}
+	if line["userIdentifier"] != hashed {
+		t.Errorf("userIdentifier = %v, want %s (hashes ignore URL encoding)", line["userIdentifier"], hashed)
+	}
+	if line["requestId"] != "req-1" || line["project"] != "payments" {
+		t.Errorf("request attributes = %v, %v", line["requestId"], line["project"])
+	}
+	if line["clientSecret"] != redactedValue || line["authorization"] != redactedValue {
+		t.Errorf("secrets = %v, %v, want redacted", line["clientSecret"], line["authorization"])
+	}
+	if line["error"] != "request failed with Bearer "+redactedValue {
+		t.Errorf("error = %v, want the bearer token redacted", line["error"])
+	}
+
+	output := buf.String()
+	for _, leaked := range []string{"alice@example.com", "Alice@Example.com", "alice%40example.com", "bob@example.com", "carol@example.com", "s3cr3t", "eyJ"} {
+		if strings.Contains(output, leaked) {
+			t.Errorf("log output contains %q: %s", leaked, output)
+		}
+	}
+}
+
+func TestLoggerPIIModes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{piiModeHash, "User " + hashEmail("alice@example.com") + " not found"},
+		{piiModeRedact, "User [email] not found"},
+		{piiModeOff, "User alice@example.com not found"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		newLogger(&buf, slog.LevelInfo, tt.mode).Info("User alice@example.com not found")
+		if lines := decodeLogLines(t, &buf); len(lines) != 1 || lines[0]["msg"] != tt.expected {
+			t.Errorf("newLogger(%s) logged %s, want msg %q", tt.mode, buf.String(), tt.expected)
+		}
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, slog.LevelWarn, piiModeHash)
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Warn("warn")
+
+	lines := decodeLogLines(t, &buf)
+	if len(lines) != 1 || lines[0]["msg"] != "warn" {
+		t.Errorf("logger at WARN wrote %s", buf.String())
+	}
+}
+
+func TestWithLogAttrsReplaces(t *testing.T) {
+	ctx := withProjectLogAttr(context.Background(), "first")
+	ctx = withLogAttrs(ctx, slog.String("requestId", "req-1"))
+	ctx = withProjectLogAttr(ctx, "second")
+
+	attrs, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	if len(attrs) != 2 || attrs[0].Key != "requestId" || attrs[1].Value.String() != "second" {
+		t.Errorf("withLogAttrs() = %v, want requestId and the latest project", attrs)
+	}
+}
+
+func TestLogLevelFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected slog.Level
+	}{
+		{"", slog.LevelInfo},
+		{"debug", slog.LevelDebug},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"verbose", slog.LevelInfo},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("LOG_LEVEL", tt.value)
+		if got := logLevelFromEnv(); got != tt.expected {
+			t.Errorf("logLevelFromEnv(%q) = %v, want %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestPIIModeFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", piiModeHash},
+		{"redact", piiModeRedact},
+		{"OFF", piiModeOff},
+		{"plain", piiModeHash},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("LOG_PII", tt.value)
+		if got := piiModeFromEnv(); got != tt.expected {
+			t.Errorf("piiModeFromEnv(%q) = %v, want %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestCallerIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		context  events.APIGatewayProxyRequestContext
+		expected string
+	}{
+		{"none", events.APIGatewayProxyRequestContext{}, ""},
+		{"IAM caller", events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{Caller: "AIDAEXAMPLE"}}, "AIDAEXAMPLE"},
+		{"IAM user ARN", events.APIGatewayProxyRequestContext{Identity: events.APIGatewayRequestIdentity{
+			Caller:  "AIDAEXAMPLE",
+			UserArn: "arn:aws:iam::123456789012:user/alice",
+		}}, "arn:aws:iam::123456789012:user/alice"},
+		{"Cognito claims", events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "abc-123", "email": "alice@example.com"}},
+			Identity:   events.APIGatewayRequestIdentity{UserArn: "arn:aws:iam::123456789012:user/alice"},
+		}, "alice@example.com"},
+		{"subject only", events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "abc-123"}},
+		}, "abc-123"},
+	}
+
+	for _, tt := range tests {
+		request := events.APIGatewayProxyRequest{RequestContext: tt.context}
+		if got := callerIdentity(request); got != tt.expected {
+			t.Errorf("callerIdentity(%s) = %q, want %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestHandleRequestLogging(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+	buf := captureLogs(t, slog.LevelDebug)
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/create-project",
+		Body:       `{"appID":"payments","userGroups":[{"userIds":"alice@example.com","role":"project-owner"}]}`,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID: "req-42",
+			Identity:  events.APIGatewayRequestIdentity{UserArn: "arn:aws:iam::123456789012:user/ops"},
+		},
+	}
+	response, err := handleRequest(context.Background(), request)
+	if err != nil || response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("handleRequest() = %d, %v", response.StatusCode, err)
+	}
+
+	// The request body is never logged verbatim
+	if strings.Contains(buf.String(), "alice@example.com") || strings.Contains(buf.String(), "userIds") {
+		t.Errorf("log output contains the request body: %s", buf.String())
+	}
+
+	lines := decodeLogLines(t, buf)
+	for _, line := range lines {
+		if line["requestId"] != "req-42" || line["caller"] != "arn:aws:iam::123456789012:user/ops" {
+			t.Errorf("log line %v is missing the request ID or caller", line)
+		}
+	}
+
+	var projectLines int
+	for _, line := range lines {
+		if line["project"] == "payments" {
+			projectLines++
+		}
+	}
+	if projectLines == 0 {
+		t.Errorf("no log line names the project: %s", buf.String())
+	}
+
+	completed := lines[len(lines)-1]
+	if completed["msg"] != "Request completed" || completed["level"] != "ERROR" || completed["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("last log line = %v, want the failed request outcome", completed)
+	}
+	if _, ok := completed["durationMs"]; !ok {
+		t.Errorf("completion line %v has no duration", completed)
+	}
+}
+
+func TestHandleRequestLoggingEncodedEmail(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+	buf := captureLogs(t, slog.LevelDebug)
+
+	request := events.APIGatewayProxyRequest{HTTPMethod: "DELETE", Path: "/api/projects/x/members/user%40example.com"}
+	if _, err := handleRequest(context.Background(), request); err != nil {
+		t.Fatalf("handleRequest() error = %v", err)
+	}
+
+	// Neither the email nor its URL-encoded form escapes redaction
+	for _, leaked := range []string{"user@example.com", "user%40example.com"} {
+		if strings.Contains(buf.String(), leaked) {
+			t.Errorf("log output contains %q: %s", leaked, buf.String())
+		}
+	}
+
+	lines := decodeLogLines(t, buf)
+	if len(lines) == 0 || lines[0]["route"] != "/api/projects/{name}/members/{user}" {
+		t.Errorf("log lines = %v, want the route template", lines)
+	}
+}
@@ -2,16 +2,22 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"net"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strings"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -23,6 +29,7 @@ import (
 	v1alphaProject "github.com/nobl9/nobl9-go/manifest/v1alpha/project"
 	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
 	"github.com/nobl9/nobl9-go/sdk"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Valid roles that can be assigned
@@ -40,15 +47,26 @@ type UserGroup struct {
 
 // CreateProjectRequest defines the request payload for creating a project
 type CreateProjectRequest struct {
-	AppID       string      `json:"appID"`       // Name of the project to create
-	Description string      `json:"description"` // Description of the project (optional)
-	UserGroups  []UserGroup `json:"userGroups"`  // List of user groups with their roles
+	AppID          string      `json:"appID"`          // Name of the project to create
+	Description    string      `json:"description"`    // Description of the project (optional)
+	UserGroups     []UserGroup `json:"userGroups"`     // List of user groups with their roles
+	DryRun         bool        `json:"dryRun"`         // Return the generated manifests instead of applying them (optional)
+	Format         string      `json:"format"`         // Dry-run manifest format: "yaml" (default) or "json" (optional)
+	DuplicateUsers string      `json:"duplicateUsers"` // Users listed with several roles: "merge" (default) or "reject" (optional)
 }
 
 // Response defines the API response structure sent back to the client
 type Response struct {
-	Success bool   `json:"success"` // Whether the operation was successful
-	Message string `json:"message"` // Human-readable message about the operation
+	Success bool         `json:"success"`          // Whether the operation was successful
+	Message string       `json:"message"`          // Human-readable message about the operation
+	Code    string       `json:"code,omitempty"`   // Stable machine-readable error code, if the operation failed
+	Errors  []FieldError `json:"errors,omitempty"` // Per-field validation errors, if any
+}
+
+// CreateProjectResponse defines the response for a created project
+type CreateProjectResponse struct {
+	Response
+	Merged []UserMerge `json:"merged,omitempty"` // Users listed more than once who were merged into one role binding
 }
 
 // HealthResponse defines the health check response structure
@@ -127,26 +145,26 @@ func validateEmail(email string) bool {
 // validateProjectName validates the project name format
 func validateProjectName(name string) error {
 	if name == "" {
-		return fmt.Errorf("project name cannot be empty")
+		return newValidationError(codeRequired, "project name cannot be empty")
 	}
 
 	if len(name) < 3 {
-		return fmt.Errorf("project name must be at least 3 characters long")
+		return newValidationError(codeTooShort, "project name must be at least 3 characters long")
 	}
 
 	if len(name) > 63 {
-		return fmt.Errorf("project name must be less than 63 characters")
+		return newValidationError(codeTooLong, "project name must be less than 63 characters")
 	}
 
 	// Check for valid characters (lowercase letters, numbers, hyphens)
 	validNameRegex := regexp.MustCompile(`^[a-z0-9-]+$`)
 	if !validNameRegex.MatchString(name) {
-		return fmt.Errorf("project name can only contain lowercase letters, numbers, and hyphens")
+		return newValidationError(codeInvalidCharacters, "project name can only contain lowercase letters, numbers, and hyphens")
 	}
 
 	// Check that it doesn't start or end with hyphen
 	if strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
-		return fmt.Errorf("project name cannot start or end with a hyphen")
+		return newValidationError(codeInvalidFormat, "project name cannot start or end with a hyphen")
 	}
 
 	return nil
@@ -161,33 +179,109 @@ func getValidRoles() string {
 	return strings.Join(roles, ", ")
 }
 
-// configureTLS sets up custom HTTP transport with TLS verification disabled if needed
-func configureTLS() {
-	if os.Getenv("NOBL9_SKIP_TLS_VERIFY") == "true" {
-		log.Println("WARNING: SSL certificate verification is DISABLED (NOBL9_SKIP_TLS_VERIFY=true)")
-
-		// Create a custom transport with TLS verification disabled
-		customTransport := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			// Copy other important settings from the default transport
-			Proxy:                 http.ProxyFromEnvironment,
-			DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
+// userIdentifierEntry is a user ID or email and its position in the comma-separated list it came from
+type userIdentifierEntry struct {
+	Position   int
+	Identifier string
+}
+
+// userIdentifierEntries splits a comma-separated list of user IDs or emails, trimming whitespace and
+// skipping empty entries. Positions count the empty entries too, so "a,,b" puts b at position 2.
+func userIdentifierEntries(userIDs string) []userIdentifierEntry {
+	var entries []userIdentifierEntry
+	for position, userIdentifier := range strings.Split(userIDs, ",") {
+		userIdentifier = strings.TrimSpace(userIdentifier)
+		if userIdentifier == "" {
+			continue // Skip empty entries
 		}
+		entries = append(entries, userIdentifierEntry{Position: position, Identifier: userIdentifier})
+	}
+	return entries
+}
 
-		// Set this as the default transport for all HTTP clients
-		http.DefaultTransport = customTransport
-	} else {
-		log.Println("TLS verification is enabled")
+// splitUserIdentifiers splits a comma-separated list of user IDs or emails,
+// trimming whitespace and skipping empty entries
+func splitUserIdentifiers(userIDs string) []string {
+	var identifiers []string
+	for _, entry := range userIdentifierEntries(userIDs) {
+		identifiers = append(identifiers, entry.Identifier)
 	}
+	return identifiers
+}
+
+// userIdentifierField returns the field path of a user identifier in the request, e.g. userGroups[1].userIds[0]
+func userIdentifierField(groupIndex, position int) string {
+	return fmt.Sprintf("userGroups[%d].userIds[%d]", groupIndex, position)
+}
+
+// userNotFoundError is returned when an email does not match any Nobl9 user
+type userNotFoundError struct {
+	identifier  string
+	suggestions []string // Known users the identifier may have been meant to be
+}
+
+// Error returns the human-readable lookup failure
+func (e *userNotFoundError) Error() string {
+	message := fmt.Sprintf("User with email '%s' not found in Nobl9", e.identifier)
+	if len(e.suggestions) > 0 {
+		message += fmt.Sprintf(". Did you mean %s?", strings.Join(e.suggestions, " or "))
+	}
+	return message
+}
+
+// isLookupUpstreamFailure reports whether a user lookup failed because Nobl9 could not serve
+// the request, rather than because the user does not exist
+func isLookupUpstreamFailure(err error) bool {
+	var notFound *userNotFoundError
+	if errors.As(err, &notFound) {
+		return false
+	}
+	return isUpstreamFailure(classifyNobl9Error(err))
+}
+
+// resolveUserID returns the Nobl9 user ID for an identifier through the shared user cache.
+// Identifiers that are not emails are assumed to already be user IDs.
+func resolveUserID(ctx context.Context, client *sdk.Client, userIdentifier string) (string, error) {
+	return lookupUserID(ctx, userLookups, client.Users().V2().GetUser, userIdentifier)
+}
+
+// roleBindingNameHashLength is the number of hex characters of the (project, user, role) hash kept in role binding names
+const roleBindingNameHashLength = 16
+
+// roleBindingName builds a deterministic role binding name for a user's role on a project.
+// The name is a readable prefix followed by a hash of (project, user ID, role), so re-applying
+// the same grant converges on the same object and truncating the prefix never causes collisions.
+func roleBindingName(projectName, userID, role string) string {
+	hash := sha256.Sum256([]byte(projectName + "\x00" + userID + "\x00" + role))
+	suffix := hex.EncodeToString(hash[:])[:roleBindingNameHashLength]
+
+	// Nobl9 names are limited to 63 characters; the prefix gets whatever the hash leaves over
+	prefix := sanitizeName(fmt.Sprintf("assign-%s-%s-%s", projectName, strings.TrimPrefix(role, "project-"), userID))
+	prefix = strings.TrimRight(truncate(prefix, 63-len(suffix)-1), "-")
+
+	return prefix + "-" + suffix
+}
+
+// newRoleBinding builds the role binding manifest granting a role on a project to a user
+func newRoleBinding(projectName, userID, role string) v1alphaRoleBinding.RoleBinding {
+	// Create the role binding object
+	return v1alphaRoleBinding.New(
+		v1alphaRoleBinding.Metadata{
+			Name: roleBindingName(projectName, userID, role),
+		},
+		v1alphaRoleBinding.Spec{
+			User:       ptr(userID), // Use the user's ID
+			RoleRef:    role,        // Role from the request
+			ProjectRef: projectName, // Project the role is granted on
+		},
+	)
 }
 
 // getNobl9Credentials retrieves and decrypts Nobl9 credentials from AWS Parameter Store and KMS
-func getNobl9Credentials(ctx context.Context) (*Nobl9Credentials, error) {
+func getNobl9Credentials(ctx context.Context) (credentials *Nobl9Credentials, err error) {
+	ctx, span := startSpan(ctx, "Get Nobl9 credentials")
+	defer func() { endSpan(span, err) }()
+
 	// Get parameter names from environment variables
 	clientIDParamName := os.Getenv("NOBL9_CLIENT_ID_PARAM_NAME")
 	clientSecretParamName := os.Getenv("NOBL9_CLIENT_SECRET_PARAM_NAME")
@@ -196,21 +290,15 @@ func getNobl9Credentials(ctx context.Context) (*Nobl9Credentials, error) {
 		return nil, fmt.Errorf("missing parameter names: NOBL9_CLIENT_ID_PARAM_NAME and NOBL9_CLIENT_SECRET_PARAM_NAME must be set")
 	}
 
-	log.Printf("Retrieving credentials from Parameter Store: %s, %s", clientIDParamName, clientSecretParamName)
+	slog.InfoContext(ctx, "Retrieving Nobl9 credentials from Parameter Store", "parameters", []string{clientIDParamName, clientSecretParamName})
 
 	// Get encrypted credentials from Parameter Store
-	clientIDParam, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
-		Name:           aws.String(clientIDParamName),
-		WithDecryption: aws.Bool(true),
-	})
+	clientIDParam, err := getSSMParameter(ctx, clientIDParamName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client ID parameter: %w", err)
 	}
 
-	clientSecretParam, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
-		Name:           aws.String(clientSecretParamName),
-		WithDecryption: aws.Bool(true),
-	})
+	clientSecretParam, err := getSSMParameter(ctx, clientSecretParamName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client secret parameter: %w", err)
 	}
@@ -221,10 +309,8 @@ func getNobl9Credentials(ctx context.Context) (*Nobl9Credentials, error) {
 
 	// Check if the values are KMS-encrypted (they start with "AQICAH")
 	if strings.HasPrefix(clientID, "AQICAH") {
-		log.Println("Decrypting client ID with KMS")
-		clientIDBytes, err := kmsClient.Decrypt(ctx, &kms.DecryptInput{
-			CiphertextBlob: []byte(clientID),
-		})
+		slog.DebugContext(ctx, "Decrypting client ID with KMS")
+		clientIDBytes, err := decryptKMS(ctx, clientID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt client ID: %w", err)
 		}
@@ -232,23 +318,67 @@ func getNobl9Credentials(ctx context.Context) (*Nobl9Credentials, error) {
 	}
 
 	if strings.HasPrefix(clientSecret, "AQICAH") {
-		log.Println("Decrypting client secret with KMS")
-		clientSecretBytes, err := kmsClient.Decrypt(ctx, &kms.DecryptInput{
-			CiphertextBlob: []byte(clientSecret),
-		})
+		slog.DebugContext(ctx, "Decrypting client secret with KMS")
+		clientSecretBytes, err := decryptKMS(ctx, clientSecret)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt client secret: %w", err)
 		}
 		clientSecret = string(clientSecretBytes.Plaintext)
 	}
 
-	log.Println("Successfully retrieved Nobl9 credentials")
+	slog.InfoContext(ctx, "Retrieved Nobl9 credentials")
 	return &Nobl9Credentials{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 	}, nil
 }
 
+// getSSMParameter reads and decrypts a Parameter Store parameter, tracing the call
+func getSSMParameter(ctx context.Context, name string) (output *ssm.GetParameterOutput, err error) {
+	ctx, span := startSpan(ctx, "SSM GetParameter",
+		attribute.String("rpc.system", "aws-api"),
+		attribute.String("aws.ssm.parameter", name),
+	)
+	defer func() { endSpan(span, err) }()
+
+	return ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+}
+
+// decryptKMS decrypts a KMS-encrypted value, tracing the call
+func decryptKMS(ctx context.Context, ciphertext string) (output *kms.DecryptOutput, err error) {
+	ctx, span := startSpan(ctx, "KMS Decrypt", attribute.String("rpc.system", "aws-api"))
+	defer func() { endSpan(span, err) }()
+
+	return kmsClient.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob: []byte(ciphertext),
+	})
+}
+
+// newNobl9Client retrieves Nobl9 credentials and initializes a Nobl9 SDK client with them
+func newNobl9Client(ctx context.Context) (*sdk.Client, error) {
+	config, err := nobl9ConfigFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Nobl9 configuration: %w", err)
+	}
+
+	// Get Nobl9 credentials from AWS Parameter Store and KMS
+	credentials, err := getNobl9Credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Nobl9 credentials: %w", err)
+	}
+	config.ClientID = credentials.ClientID
+	config.ClientSecret = credentials.ClientSecret
+
+	client, err := newNobl9ClientFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Nobl9 SDK client: %w", err)
+	}
+	return client, nil
+}
+
 // handleHealthCheck handles health check requests
 func handleHealthCheck(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// Only allow GET requests
@@ -265,7 +395,7 @@ func handleHealthCheck(ctx context.Context, request events.APIGatewayProxyReques
 
 	responseBody, err := json.Marshal(healthResponse)
 	if err != nil {
-		log.Printf("Error marshaling health response: %v", err)
+		slog.ErrorContext(ctx, "Failed to encode health response", "error", err)
 		return respondLambdaWithStatus(http.StatusInternalServerError, false, "Internal server error")
 	}
 
@@ -281,92 +411,60 @@ func handleHealthCheck(ctx context.Context, request events.APIGatewayProxyReques
 	}, nil
 }
 
-// handleCreateProject processes Lambda requests to create a new project and assign user roles
-func handleCreateProject(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Only allow POST requests
-	if request.HTTPMethod != "POST" {
-		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
-	}
-
-	log.Printf("Processing create project request: %s", request.Body)
-
-	// Parse the JSON request body into our struct
-	var req CreateProjectRequest
-	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
-		log.Printf("Error parsing request body: %v", err)
-		return respondLambdaWithStatus(http.StatusBadRequest, false, "Invalid request body: "+err.Error())
-	}
-
-	// Validate the project name
-	if err := validateProjectName(req.AppID); err != nil {
-		log.Printf("Invalid project name '%s': %v", req.AppID, err)
-		return respondLambdaWithStatus(http.StatusBadRequest, false, err.Error())
-	}
-
-	if len(req.UserGroups) == 0 {
-		log.Printf("No user groups provided for project '%s'", req.AppID)
-		return respondLambdaWithStatus(http.StatusBadRequest, false, "At least one user group is required")
-	}
-
-	// Validate all roles and user identifiers in the request
-	for groupIndex, group := range req.UserGroups {
-		if !validRoles[group.Role] {
-			log.Printf("Invalid role '%s' in group %d", group.Role, groupIndex)
-			return respondLambdaWithStatus(http.StatusBadRequest, false, fmt.Sprintf("Invalid role '%s' in group %d. Must be one of: %s", group.Role, groupIndex, getValidRoles()))
-		}
+// Outcomes of the create project pipeline, reported per project by the CLI and batch endpoints
+const (
+	createStatusCreated          = "created"
+	createStatusDryRun           = "dry_run"
+	createStatusConflict         = "conflict"
+	createStatusValidationFailed = "validation_failed"
+	createStatusLookupFailed     = "lookup_failed"
+	createStatusForbidden        = "forbidden"
+	createStatusFailed           = "failed"
+)
 
-		// Validate all user identifiers in this group
-		userIdentifiers := strings.Split(group.UserIDs, ",")
-		for _, userIdentifier := range userIdentifiers {
-			userIdentifier = strings.TrimSpace(userIdentifier)
-			if userIdentifier == "" {
-				continue // Skip empty entries
-			}
+// createProjectResult is the outcome of running the create project pipeline for one request
+type createProjectResult struct {
+	Status      string            // One of the createStatus constants
+	Message     string            // Human-readable outcome
+	FieldErrors []FieldError      // Validation errors, or users that could not be found
+	Objects     []manifest.Object // Project and role bindings applied, or that would be applied in a dry run
+	Merges      []UserMerge       // Users listed more than once who were merged into one role binding
+	Err         error             // Underlying error for lookup and Nobl9 failures
+	Operation   string            // Failed Nobl9 operation, used with Err to build the error response
+}
 
-			// Check if this looks like it's intended to be an email
-			if looksLikeEmail(userIdentifier) {
-				// This looks like it's intended to be an email, so validate it strictly
-				if !validateEmail(userIdentifier) {
-					log.Printf("Invalid email format: '%s' in group %d", userIdentifier, groupIndex)
-					return respondLambdaWithStatus(http.StatusBadRequest, false, fmt.Sprintf("Invalid email format: '%s' in group %d. Email addresses must contain @ symbol and be properly formatted (e.g., user@domain.com).", userIdentifier, groupIndex))
-				}
-			} else {
-				// This should be a user ID - validate it's reasonable
-				if len(userIdentifier) < 2 {
-					log.Printf("Invalid user ID: '%s' in group %d (too short)", userIdentifier, groupIndex)
-					return respondLambdaWithStatus(http.StatusBadRequest, false, fmt.Sprintf("Invalid user ID: '%s' in group %d (too short)", userIdentifier, groupIndex))
-				}
-			}
+// createProject validates a create project request, resolves its users and applies the project
+// and role bindings to Nobl9, or only builds the manifests in dry-run mode
+func createProject(ctx context.Context, req CreateProjectRequest, dryRun bool) (result createProjectResult) {
+	ctx = withProjectLogAttr(ctx, req.AppID)
+	defer func() { recordProjectResult(result) }()
+
+	// Validate the project name, roles and user identifiers, collecting every problem
+	if fieldErrors := validateCreateProjectRequest(req); len(fieldErrors) > 0 {
+		slog.InfoContext(ctx, "Project validation failed", "errors", len(fieldErrors))
+		return createProjectResult{
+			Status:      createStatusValidationFailed,
+			Message:     validationSummary(fieldErrors),
+			FieldErrors: fieldErrors,
 		}
 	}
 
-	log.Printf("Request validation passed for project '%s'", req.AppID)
+	slog.DebugContext(ctx, "Project validation passed")
 
-	// Get Nobl9 credentials from AWS Parameter Store and KMS
-	credentials, err := getNobl9Credentials(ctx)
-	if err != nil {
-		log.Printf("Failed to retrieve Nobl9 credentials: %v", err)
-		return respondLambdaWithStatus(http.StatusInternalServerError, false, "Failed to retrieve Nobl9 credentials: "+err.Error())
+	// Check the caller may create this project and grant these roles before touching Nobl9
+	if err := authorizeCreateProject(ctx, req); err != nil {
+		return createProjectResult{Status: createStatusForbidden, Message: err.Error()}
 	}
 
-	// Set environment variables for the Nobl9 SDK
-	os.Setenv("NOBL9_SDK_CLIENT_ID", credentials.ClientID)
-	os.Setenv("NOBL9_SDK_CLIENT_SECRET", credentials.ClientSecret)
-	
-	// Fix for Lambda: Set HOME to /tmp to avoid "HOME is not defined" error
-	// The Nobl9 SDK tries to access the HOME directory for config/cache files
-	// but in Lambda, $HOME points to a non-existent read-only directory
-	os.Setenv("HOME", "/tmp")
-
 	// Create a context with timeout for all SDK operations
 	sdkCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
-	// Initialize the Nobl9 client using the same method as your CLI tool
-	client, err := sdk.DefaultClient()
+	// Initialize the Nobl9 client using credentials from Parameter Store
+	client, err := getNobl9Client(ctx)
 	if err != nil {
-		log.Printf("Failed to initialize Nobl9 SDK client: %v", err)
-		return respondLambdaWithStatus(http.StatusInternalServerError, false, "Failed to initialize Nobl9 SDK client: "+err.Error())
+		slog.ErrorContext(ctx, "Failed to initialize Nobl9 client", "error", err)
+		return createProjectResult{Status: createStatusFailed, Message: err.Error(), Err: err}
 	}
 
 	// Step 1: Check if project already exists
@@ -391,91 +489,51 @@ func handleCreateProject(ctx context.Context, request events.APIGatewayProxyRequ
 		},
 	)
 
-	log.Printf("Creating project '%s' with description: %s", req.AppID, description)
+	slog.InfoContext(ctx, "Creating project", "description", description, "userGroups", len(req.UserGroups))
 
 	// Step 3: Prepare role bindings for each user group
 	var roleBindings []manifest.Object
-	var errors []string
-
-	// Process each user group
-	for groupIndex, group := range req.UserGroups {
-		// Split the comma-separated user IDs/emails
-		userIdentifiers := strings.Split(group.UserIDs, ",")
-
-		// Process each user in the group
-		for _, userIdentifier := range userIdentifiers {
-			userIdentifier = strings.TrimSpace(userIdentifier)
-			if userIdentifier == "" {
-				continue // Skip empty entries
-			}
 
-			// We already validated the format above, so now we just need to process
-			var userID string
-			if strings.Contains(userIdentifier, "@") {
-				// This is an email, try to get the user by email
-				log.Printf("Looking up user by email: %s", userIdentifier)
-				user, err := client.Users().V2().GetUser(sdkCtx, userIdentifier)
-				if err != nil {
-					errorMsg := fmt.Sprintf("Error retrieving user '%s': %v", userIdentifier, err)
-					log.Print(errorMsg)
-					errors = append(errors, errorMsg)
-					continue
-				}
-				if user == nil {
-					errorMsg := fmt.Sprintf("User with email '%s' not found in Nobl9", userIdentifier)
-					log.Print(errorMsg)
-					errors = append(errors, errorMsg)
-					continue
-				}
-				userID = user.UserID
-				log.Printf("Found user: %s -> %s", userIdentifier, userID)
-			} else {
-				// This is a user ID
-				userID = userIdentifier
-				log.Printf("Using provided user ID: %s", userID)
-			}
-
-			// Generate a unique name for the role binding
-			// Use the same naming convention as your CLI tool
-			sanitizedProject := sanitizeName(req.AppID)
-			sanitizedUser := sanitizeName(userIdentifier)
-			// Truncate components to ensure the final name is within the 63-char limit required by Nobl9.
-			// The name has a fixed overhead: "assign--gX-" + a 10-digit timestamp = ~22 chars.
-			// This leaves ~41 chars for the project and user. We'll allocate 20 to each.
-			truncatedProject := truncate(sanitizedProject, 20)
-			truncatedUser := truncate(sanitizedUser, 20)
-
-			roleBindingName := fmt.Sprintf("assign-%s-%s-g%d-%d",
-				truncatedProject,
-				truncatedUser,
-				groupIndex,
-				time.Now().Unix())
-
-			// Create the role binding object
-			roleBinding := v1alphaRoleBinding.New(
-				v1alphaRoleBinding.Metadata{
-					Name: roleBindingName,
-				},
-				v1alphaRoleBinding.Spec{
-					User:       ptr(userID), // Use the user's ID
-					RoleRef:    group.Role,  // Role from the request
-					ProjectRef: req.AppID,   // Project we just created
-				},
-			)
-
-			roleBindings = append(roleBindings, roleBinding)
-			log.Printf("Created role binding manifest: %s for user %s with role %s", roleBindingName, userID, group.Role)
+	// Look up every user up front, in parallel
+	lookups := resolveUserIDs(sdkCtx, client, req.UserGroups)
+	assignments, notFound, lookupErrors, err := assignUsers(ctx, req.UserGroups, lookups)
+	if err != nil {
+		return createProjectResult{
+			Status:    createStatusLookupFailed,
+			Message:   fmt.Sprintf("Failed to look up users in Nobl9: %v", err),
+			Err:       err,
+			Operation: "Failed to look up users in Nobl9",
 		}
 	}
 
 	// If we had errors finding users, we can't proceed.
 	// The project has not been created yet, so we just report the errors.
-	if len(errors) > 0 {
+	if len(notFound) > 0 {
 		errorMsg := fmt.Sprintf("Failed to create project '%s' because some users could not be found:\n• %s",
-			req.AppID, strings.Join(errors, "\n• "))
-		log.Print(errorMsg)
-		return respondLambdaWithStatus(http.StatusBadRequest, false, errorMsg)
+			req.AppID, strings.Join(notFound, "\n• "))
+		slog.InfoContext(ctx, "Some users could not be found", "users", len(notFound))
+		return createProjectResult{Status: createStatusLookupFailed, Message: errorMsg, FieldErrors: lookupErrors}
+	}
+
+	// Give every user a single role, merging users listed more than once
+	assignments, merges, conflicts := normalizeAssignments(assignments, req.DuplicateUsers == duplicateUsersReject)
+	if len(conflicts) > 0 {
+		slog.InfoContext(ctx, "Users listed with conflicting roles", "users", len(conflicts))
+		return createProjectResult{
+			Status:      createStatusValidationFailed,
+			Message:     validationSummary(conflicts),
+			FieldErrors: conflicts,
+		}
+	}
+	logMerges(ctx, merges)
+
+	_, manifestSpan := startSpan(ctx, "Build manifests", attribute.Int("nobl9.role_bindings", len(assignments)))
+	for _, assignment := range assignments {
+		roleBinding := newRoleBinding(req.AppID, assignment.UserID, assignment.Role)
+		roleBindings = append(roleBindings, roleBinding)
+		slog.DebugContext(ctx, "Created role binding manifest", "roleBinding", roleBinding.Metadata.Name, "userId", assignment.UserID, "role", assignment.Role)
 	}
+	endSpan(manifestSpan, nil)
 
 	// Step 4: Apply the project and all role bindings in a single atomic operation
 	allObjects := []manifest.Object{project}
@@ -483,24 +541,100 @@ func handleCreateProject(ctx context.Context, request events.APIGatewayProxyRequ
 		allObjects = append(allObjects, roleBindings...)
 	}
 
-	log.Printf("Applying %d objects to Nobl9 (1 project + %d role bindings)", len(allObjects), len(roleBindings))
+	// In dry-run mode, return the manifests that would have been applied
+	if dryRun {
+		return createProjectResult{
+			Status: createStatusDryRun,
+			Message: fmt.Sprintf("Dry run for project '%s': %d objects would be applied (1 project + %d role bindings)",
+				req.AppID, len(allObjects), len(roleBindings)),
+			Objects: allObjects,
+			Merges:  merges,
+		}
+	}
+
+	slog.InfoContext(ctx, "Applying objects to Nobl9", "objects", len(allObjects), "roleBindings", len(roleBindings))
 
-	if err := client.Objects().V1().Apply(sdkCtx, allObjects); err != nil {
+	if err := applyObjects(sdkCtx, "create_project", allObjects, client.Objects().V1().Apply); err != nil {
 		// Check if the error is because the project already exists
-		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "conflict") {
-			log.Printf("Project '%s' already exists", req.AppID)
-			return respondLambdaWithStatus(http.StatusConflict, false, fmt.Sprintf("Project '%s' already exists", req.AppID))
+		if classifyNobl9Error(err).Code == codeConflict {
+			slog.InfoContext(ctx, "Project already exists")
+			return createProjectResult{
+				Status:  createStatusConflict,
+				Message: fmt.Sprintf("Project '%s' already exists", req.AppID),
+				Err:     err,
+			}
 		}
 
-		log.Printf("Failed to create project and assign roles: %v", err)
-		return respondLambdaWithStatus(http.StatusInternalServerError, false, fmt.Sprintf("Failed to create project and assign roles: %v", err))
+		slog.ErrorContext(ctx, "Failed to create project and assign roles", "error", err)
+		return createProjectResult{
+			Status:    createStatusFailed,
+			Message:   fmt.Sprintf("Failed to create project and assign roles: %v", err),
+			Err:       err,
+			Operation: "Failed to create project and assign roles",
+		}
 	}
 
-	log.Printf("Successfully created project '%s' and applied %d role bindings", req.AppID, len(roleBindings))
+	slog.InfoContext(ctx, "Created project", "roleBindings", len(roleBindings))
 
-	// Success! Report back to the client
-	message := fmt.Sprintf("Project '%s' created successfully with %d user role assignments", req.AppID, len(roleBindings))
-	return respondLambda(true, message)
+	return createProjectResult{
+		Status:  createStatusCreated,
+		Message: fmt.Sprintf("Project '%s' created successfully with %d user role assignments", req.AppID, len(roleBindings)),
+		Objects: allObjects,
+		Merges:  merges,
+	}
+}
+
+// respondCreateProjectResult sends the API response for a create project pipeline result
+func respondCreateProjectResult(projectName string, result createProjectResult, format manifest.ObjectFormat) (events.APIGatewayProxyResponse, error) {
+	switch {
+	case result.Status == createStatusValidationFailed:
+		return respondValidationErrors(result.FieldErrors)
+	case result.Status == createStatusDryRun:
+		return respondDryRun(projectName, result.Objects, format, result.Merges)
+	case result.Status == createStatusCreated:
+		return respondLambdaJSON(http.StatusOK, CreateProjectResponse{
+			Response: Response{
+				Success: true,
+				Message: result.Message,
+			},
+			Merged: result.Merges,
+		})
+	case result.Status == createStatusConflict:
+		return respondLambdaError(http.StatusConflict, codeConflict, result.Message, nil)
+	case result.Status == createStatusForbidden:
+		return respondLambdaError(http.StatusForbidden, codeForbidden, result.Message, nil)
+	case result.Operation != "":
+		return respondNobl9Error(result.Err, result.Operation)
+	case result.Status == createStatusLookupFailed:
+		return respondLambdaError(http.StatusBadRequest, codeUserNotFound, result.Message, result.FieldErrors)
+	default:
+		return respondLambdaWithStatus(http.StatusInternalServerError, false, result.Message)
+	}
+}
+
+// handleCreateProject processes Lambda requests to create a new project and assign user roles
+func handleCreateProject(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Only allow POST requests
+	if request.HTTPMethod != "POST" {
+		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
+	}
+
+	// Parse the JSON request body into our struct
+	var req CreateProjectRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		slog.InfoContext(ctx, "Invalid create project request body", "error", err)
+		return respondLambdaWithStatus(http.StatusBadRequest, false, "Invalid request body: "+err.Error())
+	}
+
+	slog.InfoContext(ctx, "Processing create project request", "project", req.AppID, "userGroups", len(req.UserGroups), "dryRun", req.DryRun)
+
+	// Resolve dry-run options from the query string and request body
+	dryRun, format, err := parseDryRunOptions(request, req)
+	if err != nil {
+		return respondLambdaWithStatus(http.StatusBadRequest, false, err.Error())
+	}
+
+	return respondCreateProjectResult(req.AppID, createProject(ctx, req, dryRun), format)
 }
 
 // respondLambda sends a JSON response for Lambda with 200 status code
@@ -508,6 +642,15 @@ func respondLambda(success bool, message string) (events.APIGatewayProxyResponse
 	return respondLambdaWithStatus(http.StatusOK, success, message)
 }
 
+// capitalizeMessage turns an error string, which starts in lower case, into a response message
+func capitalizeMessage(message string) string {
+	if message == "" {
+		return message
+	}
+	r, size := utf8.DecodeRuneInString(message)
+	return string(unicode.ToUpper(r)) + message[size:]
+}
+
 // respondLambdaWithStatus sends a JSON response for Lambda with custom status code
 func respondLambdaWithStatus(statusCode int, success bool, message string) (events.APIGatewayProxyResponse, error) {
 	// Create the response object
@@ -516,10 +659,16 @@ func respondLambdaWithStatus(statusCode int, success bool, message string) (even
 		Message: message,
 	}
 
+	return respondLambdaJSON(statusCode, response)
+}
+
+// respondLambdaJSON sends any JSON-serializable payload for Lambda with custom status code.
+// Payloads should embed Response so clients can always rely on success/message.
+func respondLambdaJSON(statusCode int, payload interface{}) (events.APIGatewayProxyResponse, error) {
 	// Encode the JSON response
-	responseBody, err := json.Marshal(response)
+	responseBody, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshaling JSON response: %v", err)
+		slog.Error("Failed to encode JSON response", "error", err)
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body:       "Internal server error",
@@ -529,13 +678,6 @@ func respondLambdaWithStatus(statusCode int, success bool, message string) (even
 		}, nil
 	}
 
-	// Log the response for debugging
-	if success {
-		log.Printf("SUCCESS: %s", message)
-	} else {
-		log.Printf("ERROR: %s", message)
-	}
-
 	// Return the Lambda response
 	return events.APIGatewayProxyResponse{
 		StatusCode: statusCode,
@@ -543,28 +685,49 @@ func respondLambdaWithStatus(statusCode int, success bool, message string) (even
 		Headers: map[string]string{
 			"Content-Type":                 "application/json",
 			"Access-Control-Allow-Origin":  "*",
-			"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
-			"Access-Control-Allow-Headers": "Content-Type,Authorization,X-Amz-Date,X-Api-Key,X-Amz-Security-Token",
+			"Access-Control-Allow-Methods": "GET, POST, DELETE, OPTIONS",
+			"Access-Control-Allow-Headers": "Content-Type,Authorization,X-Amz-Date,X-Api-Key,X-Amz-Security-Token,Idempotency-Key",
 		},
 	}, nil
 }
 
-// handleRequest is the main Lambda handler that routes requests to appropriate handlers
+// handleRequest authenticates a request if bearer tokens are required and handles it on behalf of its caller,
+// tracing it and logging it with its request ID, caller and outcome
 func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Configure TLS before processing any requests
-	configureTLS()
-
-	// Log incoming request
-	log.Printf("Received %s request to %s", request.HTTPMethod, request.Path)
+	ctx, span := startRequestSpan(ctx, request)
+
+	// Validate bearer tokens first, so logs and authorization see the token's caller
+	request, authErr := authenticateRequest(jwtAuth, request)
+	ctx = requestLogContext(ctx, request)
+	ctx = withCaller(ctx, callerFromRequest(request))
+	slog.DebugContext(ctx, "Received request")
+
+	start := time.Now()
+	var response events.APIGatewayProxyResponse
+	var err error
+	if authErr != nil {
+		slog.InfoContext(ctx, "Rejected bearer token", "reason", authErr.Error())
+		response, err = respondUnauthorized(authErr)
+	} else {
+		response, err = routeRequest(ctx, request)
+	}
+	duration := time.Since(start)
+	endRequestSpan(span, response, err)
+	logResponse(ctx, response, err, duration)
+	recordRequest(request, response, err, duration)
+	return response, err
+}
 
+// routeRequest routes requests to the appropriate handlers
+func routeRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// Handle CORS preflight requests
 	if request.HTTPMethod == "OPTIONS" {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusOK,
 			Headers: map[string]string{
 				"Access-Control-Allow-Origin":  "*",
-				"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
-				"Access-Control-Allow-Headers": "Content-Type,Authorization,X-Amz-Date,X-Api-Key,X-Amz-Security-Token",
+				"Access-Control-Allow-Methods": "GET, POST, DELETE, OPTIONS",
+				"Access-Control-Allow-Headers": "Content-Type,Authorization,X-Amz-Date,X-Api-Key,X-Amz-Security-Token,Idempotency-Key",
 			},
 		}, nil
 	}
@@ -574,32 +737,125 @@ func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 	case "/health":
 		return handleHealthCheck(ctx, request)
 	case "/api/create-project":
-		return handleCreateProject(ctx, request)
-	default:
-		log.Printf("404 Not Found: %s", request.Path)
-		return respondLambdaWithStatus(http.StatusNotFound, false, "Not found")
+		return withIdempotency(ctx, request, idempotencyStore, handleCreateProject)
+	case batchProjectsPath:
+		return withIdempotency(ctx, request, idempotencyStore, handleBatchCreateProjects)
+	case importProjectsPath:
+		return withIdempotency(ctx, request, idempotencyStore, handleImportProjects)
+	case userLookupPath:
+		return handleUserLookup(ctx, request)
+	}
+
+	// Route project-scoped requests (/api/projects/{name}/...)
+	if projectName, rest, ok := parseProjectPath(request.Path); ok {
+		ctx = withProjectLogAttr(ctx, projectName)
+		switch {
+		case len(rest) == 0:
+			return handleGetProject(ctx, request, projectName)
+		case len(rest) == 1 && rest[0] == "members":
+			return handleAddMembers(ctx, request, projectName)
+		case len(rest) == 2 && rest[0] == "members":
+			return handleRemoveMember(ctx, request, projectName, rest[1])
+		}
 	}
+
+	return respondLambdaWithStatus(http.StatusNotFound, false, "Not found")
 }
 
-// init initializes AWS clients and other global resources
-func init() {
-	log.Println("Initializing Nobl9 Wizard Lambda function...")
+// initialize sets up AWS clients and other global resources
+func initialize(ctx context.Context) error {
+	slog.InfoContext(ctx, "Initializing Nobl9 Wizard", "version", appVersion)
 
 	// Load AWS configuration
-	cfg, err := config.LoadDefaultConfig(context.Background())
+	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		log.Fatalf("Failed to load AWS config: %v", err)
+		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
 	// Initialize AWS service clients
 	kmsClient = kms.NewFromConfig(cfg)
 	ssmClient = ssm.NewFromConfig(cfg)
 
-	log.Println("AWS clients initialized successfully")
+	// Size the caches shared by warm invocations
+	nobl9Clients = newNobl9ClientCache(newNobl9Client, nobl9CredentialsTTLFromEnv())
+	userLookups = newUserLookupCache(userLookupCacheTTLFromEnv())
+	knownUsers = newKnownUsersCache(knownUsersTTLFromEnv())
+
+	// Build the HTTP transport for Nobl9 requests once per container
+	if err := installNobl9Transport(ctx, ssmClient); err != nil {
+		return fmt.Errorf("failed to configure Nobl9 HTTP transport: %w", err)
+	}
+
+	// Set up bearer token authentication, if an issuer is configured
+	jwtAuth, err = newJWTAuthenticatorFromEnv(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to set up JWT authentication: %w", err)
+	}
+
+	// Load the authorization policy, if one is configured
+	authzPolicy, err = loadAuthzPolicy(ctx, ssmClient)
+	if err != nil {
+		return fmt.Errorf("failed to load authorization policy: %w", err)
+	}
+
+	// Initialize the idempotency store for create-project retries
+	idempotencyStore = newIdempotencyStoreFromEnv(cfg)
+	idempotencyTTL = idempotencyTTLFromEnv()
+
+	slog.InfoContext(ctx, "AWS clients initialized")
+	return nil
 }
 
-// main function starts the Lambda handler
+// main starts the Lambda handler, the standalone HTTP server with -server or NOBL9_WIZARD_SERVER=true,
+// or runs a CLI command such as "create -f project.yaml"
 func main() {
-	log.Println("Starting Nobl9 Wizard Lambda function...")
-	lambda.Start(handleRequest)
+	setupLogging()
+	setupMetrics()
+	if err := setupTracing(context.Background()); err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 {
+		if command, ok := cliCommands[os.Args[1]]; ok {
+			// Metrics are only published by the Lambda function and the server
+			metrics.enabled = false
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			if err := initialize(ctx); err != nil {
+				slog.Error("Failed to initialize", "error", err)
+				os.Exit(1)
+			}
+			// The authorization policy governs API callers; the CLI runs with the operator's own access
+			authzPolicy = nil
+			exitCode := command(ctx, os.Args[2:], os.Stdin, os.Stdout, os.Stderr)
+			stop()
+			shutdownTracing(context.Background())
+			os.Exit(exitCode)
+		}
+	}
+
+	serverMode := flag.Bool("server", serverModeFromEnv(), "run as a standalone HTTP server instead of a Lambda function")
+	addr := flag.String("addr", listenAddrFromEnv(), "listen address for the HTTP server")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := initialize(ctx); err != nil {
+		slog.Error("Failed to initialize", "error", err)
+		os.Exit(1)
+	}
+
+	if !*serverMode {
+		slog.Info("Starting Nobl9 Wizard Lambda function")
+		lambda.Start(handleLambdaEvent)
+		return
+	}
+
+	err := runServer(ctx, *addr, handleRequest)
+	shutdownTracing(context.Background())
+	if err != nil {
+		slog.Error("HTTP server failed", "error", err)
+		os.Exit(1)
+	}
 }
@@ -63,6 +63,24 @@ func TestTruncate(t *testing.T) {
 	}
 }
 
+func TestCapitalizeMessage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"invalid request body: EOF", "Invalid request body: EOF"},
+		{"Already capitalized", "Already capitalized"},
+		{"élan", "Élan"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if result := capitalizeMessage(tt.input); result != tt.expected {
+			t.Errorf("capitalizeMessage(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
 func TestLooksLikeEmail(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -147,6 +165,86 @@ func TestGetValidRoles(t *testing.T) {
 	}
 }
 
+func TestSplitUserIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"user@example.com", []string{"user@example.com"}},
+		{" a@example.com , b@example.com ", []string{"a@example.com", "b@example.com"}},
+		{"a@example.com,,user-id", []string{"a@example.com", "user-id"}},
+		{"", nil},
+		{" , ", nil},
+	}
+
+	for _, tt := range tests {
+		result := splitUserIdentifiers(tt.input)
+		if strings.Join(result, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("splitUserIdentifiers(%q) = %v, want %v", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestRoleBindingName(t *testing.T) {
+	longProject := strings.Repeat("payments-platform-", 4)[:63]
+
+	tests := []struct {
+		name    string
+		project string
+		userID  string
+		role    string
+		prefix  string
+	}{
+		{"short names", "my-project", "00u1abcd2EFGH3ijk4l5", "project-owner", "assign-my-project-owner-00u1abcd2efgh3ijk4l5-"},
+		{"long project", longProject, "00u1abcd2EFGH3ijk4l5", "project-viewer", "assign-payments-platform-payments-platform-"},
+	}
+
+	for _, tt := range tests {
+		name := roleBindingName(tt.project, tt.userID, tt.role)
+		if len(name) > 63 {
+			t.Errorf("roleBindingName(%s) = %q exceeds 63 characters", tt.name, name)
+		}
+		if !strings.HasPrefix(name, tt.prefix) {
+			t.Errorf("roleBindingName(%s) = %q, want prefix %q", tt.name, name, tt.prefix)
+		}
+		if strings.Contains(name, "--") || strings.HasSuffix(name, "-") {
+			t.Errorf("roleBindingName(%s) = %q is not a valid name", tt.name, name)
+		}
+		if again := roleBindingName(tt.project, tt.userID, tt.role); again != name {
+			t.Errorf("roleBindingName(%s) is not deterministic: %q != %q", tt.name, name, again)
+		}
+	}
+
+	// Inputs that are identical after sanitizing and truncating must still get distinct names
+	distinct := map[string]string{}
+	for _, input := range [][3]string{
+		{longProject, "00u1abcd2EFGH3ijk4l5", "project-owner"},
+		{longProject, "00u1abcd2EFGH3ijk4l6", "project-owner"},
+		{longProject, "00u1abcd2EFGH3ijk4l5", "project-editor"},
+		{longProject + "x", "00u1abcd2EFGH3ijk4l5", "project-owner"},
+		{"my-project", "user.one", "project-owner"},
+		{"my-project", "user-one", "project-owner"},
+	} {
+		name := roleBindingName(input[0], input[1], input[2])
+		if previous, ok := distinct[name]; ok {
+			t.Errorf("roleBindingName(%v) collides with %s: %q", input, previous, name)
+		}
+		distinct[name] = fmt.Sprint(input)
+	}
+}
+
+func TestNewRoleBinding(t *testing.T) {
+	roleBinding := newRoleBinding("my-project", "user-1", "project-editor")
+
+	if roleBinding.Metadata.Name != roleBindingName("my-project", "user-1", "project-editor") {
+		t.Errorf("newRoleBinding() name = %q", roleBinding.Metadata.Name)
+	}
+
+	if *roleBinding.Spec.User != "user-1" || roleBinding.Spec.RoleRef != "project-editor" || roleBinding.Spec.ProjectRef != "my-project" {
+		t.Errorf("newRoleBinding() spec = %+v", roleBinding.Spec)
+	}
+}
+
 func TestHandleHealthCheck(t *testing.T) {
 	// Test valid GET request
 	request := events.APIGatewayProxyRequest{
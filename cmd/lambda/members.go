@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nobl9/nobl9-go/manifest"
+	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
+)
+
+// AddMembersRequest defines the request payload for granting roles on an existing project
+type AddMembersRequest struct {
+	UserGroups     []UserGroup `json:"userGroups"`     // List of user groups with their roles
+	DuplicateUsers string      `json:"duplicateUsers"` // Users listed with several roles: "merge" (default) or "reject" (optional)
+}
+
+// AddMembersResponse defines the response for the add-members endpoint
+type AddMembersResponse struct {
+	Response
+	Added       []RoleBindingDetails `json:"added"`                 // Role bindings that were applied
+	Skipped     []RoleBindingDetails `json:"skipped"`               // Users that already held the requested role or a higher one
+	Replaced    []RoleBindingDetails `json:"replaced,omitempty"`    // Lower roles deleted from users who were granted a higher one
+	NotReplaced []RoleBindingDetails `json:"notReplaced,omitempty"` // Lower roles that could not be deleted, so their users hold both roles
+	Merged      []UserMerge          `json:"merged,omitempty"`      // Users listed more than once who were merged into one role binding
+}
+
+// codeRolesNotReplaced reports new roles that were granted while the lower roles they replace are still held
+const codeRolesNotReplaced = "roles_not_replaced"
+
+// existingMemberships indexes the role bindings held by users by user ID
+func existingMemberships(roleBindings []v1alphaRoleBinding.RoleBinding) map[string][]v1alphaRoleBinding.RoleBinding {
+	memberships := make(map[string][]v1alphaRoleBinding.RoleBinding, len(roleBindings))
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Spec.User == nil {
+			continue
+		}
+		memberships[*roleBinding.Spec.User] = append(memberships[*roleBinding.Spec.User], roleBinding)
+	}
+	return memberships
+}
+
+// memberChanges are the role bindings to apply and delete to grant roles on an existing project
+type memberChanges struct {
+	Apply     []manifest.Object    // New role bindings
+	Delete    []manifest.Object    // Existing role bindings replaced by a higher role
+	Added     []RoleBindingDetails // Details of the new role bindings
+	Skipped   []RoleBindingDetails // Existing role bindings that already cover the requested role
+	Replaced  []RoleBindingDetails // Details of the replaced role bindings
+	Conflicts []FieldError         // Users holding a different role, when duplicates are rejected
+}
+
+// planMemberChanges works out how to grant every assignment on a project, given the role bindings users
+// already hold there. As with users listed more than once, each user ends up with a single role, the one
+// with the highest precedence: a lower existing role is replaced and a higher one is kept. If reject is
+// set, a user holding a different role is reported as a conflict instead.
+func planMemberChanges(projectName string, assignments []userAssignment, memberships map[string][]v1alphaRoleBinding.RoleBinding, reject bool) memberChanges {
+	changes := memberChanges{Added: []RoleBindingDetails{}, Skipped: []RoleBindingDetails{}}
+	for _, assignment := range assignments {
+		email := ""
+		if strings.Contains(assignment.Identifier, "@") {
+			email = assignment.Identifier
+		}
+		details := func(name, role string) RoleBindingDetails {
+			return RoleBindingDetails{Name: name, Role: role, UserID: assignment.UserID, Email: email}
+		}
+
+		// Find the binding for the requested role, if any, and the user's highest role
+		held := memberships[assignment.UserID]
+		var same, highest *v1alphaRoleBinding.RoleBinding
+		for i := range held {
+			if held[i].Spec.RoleRef == assignment.Role {
+				same = &held[i]
+			}
+			if highest == nil || rolePrecedence[held[i].Spec.RoleRef] > rolePrecedence[highest.Spec.RoleRef] {
+				highest = &held[i]
+			}
+		}
+
+		switch {
+		case same != nil:
+			changes.Skipped = append(changes.Skipped, details(same.Metadata.Name, same.Spec.RoleRef))
+		case highest != nil && reject:
+			changes.Conflicts = append(changes.Conflicts, FieldError{
+				Field:   assignment.Field,
+				Code:    codeConflictingRoles,
+				Message: fmt.Sprintf("User '%s' already holds %s on project '%s' and cannot also be granted %s", assignment.Identifier, highest.Spec.RoleRef, projectName, assignment.Role),
+				Value:   assignment.Identifier,
+			})
+			continue
+		case highest != nil && rolePrecedence[highest.Spec.RoleRef] > rolePrecedence[assignment.Role]:
+			changes.Skipped = append(changes.Skipped, details(highest.Metadata.Name, highest.Spec.RoleRef))
+			continue
+		default:
+			roleBinding := newRoleBinding(projectName, assignment.UserID, assignment.Role)
+			changes.Apply = append(changes.Apply, roleBinding)
+			changes.Added = append(changes.Added, details(roleBinding.Metadata.Name, roleBinding.Spec.RoleRef))
+		}
+
+		// Lower roles are replaced rather than kept alongside the new one. A user who already holds both,
+		// because an earlier request could not delete the lower one, has it deleted now.
+		for _, roleBinding := range held {
+			if rolePrecedence[roleBinding.Spec.RoleRef] < rolePrecedence[assignment.Role] {
+				changes.Delete = append(changes.Delete, roleBinding)
+				changes.Replaced = append(changes.Replaced, details(roleBinding.Metadata.Name, roleBinding.Spec.RoleRef))
+			}
+		}
+	}
+	return changes
+}
+
+// handleAddMembers grants roles on an already-existing project, skipping users who already hold them
+func handleAddMembers(ctx context.Context, request events.APIGatewayProxyRequest, projectName string) (events.APIGatewayProxyResponse, error) {
+	// Only allow POST requests
+	if request.HTTPMethod != "POST" {
+		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
+	}
+
+	slog.InfoContext(ctx, "Processing add members request")
+
+	if err := validateProjectName(projectName); err != nil {
+		return respondLambdaWithStatus(http.StatusBadRequest, false, err.Error())
+	}
+
+	// Parse the JSON request body into our struct
+	var req AddMembersRequest
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		slog.InfoContext(ctx, "Invalid add members request body", "error", err)
+		return respondLambdaWithStatus(http.StatusBadRequest, false, "Invalid request body: "+err.Error())
+	}
+
+	// Validate all roles and user identifiers in the request, collecting every problem
+	fieldErrors := append(validateUserGroups(req.UserGroups), validateDuplicateUsersOption(req.DuplicateUsers)...)
+	if len(fieldErrors) > 0 {
+		slog.InfoContext(ctx, "Add members validation failed", "errors", len(fieldErrors))
+		return respondValidationErrors(fieldErrors)
+	}
+
+	if err := authorize(ctx, authzRequest{Project: projectName, Roles: userGroupRoles(req.UserGroups)}); err != nil {
+		return respondLambdaError(http.StatusForbidden, codeForbidden, err.Error(), nil)
+	}
+
+	// Create a context with timeout for all SDK operations
+	sdkCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	client, err := getNobl9Client(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to initialize Nobl9 client", "error", err)
+		return respondLambdaWithStatus(http.StatusInternalServerError, false, err.Error())
+	}
+
+	// Verify the project exists before granting anything on it
+	project, err := getProject(sdkCtx, client, projectName)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to retrieve project", "error", err)
+		return respondNobl9Error(err, fmt.Sprintf("Failed to retrieve project '%s'", projectName))
+	}
+	if project == nil {
+		return respondLambdaWithStatus(http.StatusNotFound, false, fmt.Sprintf("Project '%s' not found", projectName))
+	}
+
+	roleBindings, err := getProjectRoleBindings(sdkCtx, client, projectName)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to retrieve role bindings", "error", err)
+		return respondNobl9Error(err, fmt.Sprintf("Failed to retrieve role bindings for project '%s'", projectName))
+	}
+	lookups := resolveUserIDs(sdkCtx, client, req.UserGroups)
+	assignments, notFound, lookupErrors, err := assignUsers(ctx, req.UserGroups, lookups)
+	if err != nil {
+		return respondNobl9Error(err, "Failed to look up users in Nobl9")
+	}
+
+	// If we had errors finding users, we can't proceed
+	if len(notFound) > 0 {
+		errorMsg := fmt.Sprintf("Failed to add members to project '%s' because some users could not be found:\n• %s",
+			projectName, strings.Join(notFound, "\n• "))
+		return respondLambdaError(http.StatusBadRequest, codeUserNotFound, errorMsg, lookupErrors)
+	}
+
+	// Give every user a single role, merging users listed more than once
+	reject := req.DuplicateUsers == duplicateUsersReject
+	assignments, merges, conflicts := normalizeAssignments(assignments, reject)
+	if len(conflicts) > 0 {
+		return respondValidationErrors(conflicts)
+	}
+	logMerges(ctx, merges)
+
+	// Apply role bindings only for users who do not already hold the role or a higher one
+	changes := planMemberChanges(projectName, assignments, existingMemberships(roleBindings), reject)
+	if len(changes.Conflicts) > 0 {
+		return respondValidationErrors(changes.Conflicts)
+	}
+
+	// Replacing a role revokes it, and callers may only revoke roles they could grant
+	if len(changes.Replaced) > 0 {
+		var roles []string
+		for _, replaced := range changes.Replaced {
+			if !containsString(roles, replaced.Role) {
+				roles = append(roles, replaced.Role)
+			}
+		}
+		if err := authorize(ctx, authzRequest{Project: projectName, Roles: roles}); err != nil {
+			return respondLambdaError(http.StatusForbidden, codeForbidden, err.Error(), nil)
+		}
+	}
+
+	return applyMemberChanges(sdkCtx, projectName, changes, merges, client.Objects().V1().Apply, client.Objects().V1().Delete)
+}
+
+// applyMemberChanges applies the new role bindings with apply, then deletes the ones they replace with remove.
+// The new roles go first, so users never lose access. If the replaced roles cannot be deleted, the response
+// lists them in notReplaced: their users hold both roles until the request is retried.
+func applyMemberChanges(ctx context.Context, projectName string, changes memberChanges, merges []UserMerge, apply, remove func(ctx context.Context, objects []manifest.Object) error) (events.APIGatewayProxyResponse, error) {
+	if len(changes.Apply) > 0 {
+		slog.InfoContext(ctx, "Applying role bindings to Nobl9", "roleBindings", len(changes.Apply))
+
+		if err := applyObjects(ctx, "add_members", changes.Apply, apply); err != nil {
+			slog.ErrorContext(ctx, "Failed to assign roles", "error", err)
+			return respondNobl9Error(err, fmt.Sprintf("Failed to assign roles on project '%s'", projectName))
+		}
+	}
+
+	response := AddMembersResponse{
+		Response: Response{Success: true},
+		Added:    changes.Added,
+		Merged:   merges,
+		Skipped:  changes.Skipped,
+	}
+	message := fmt.Sprintf("Added %d user role assignments to project '%s' (%d already present)", len(changes.Added), projectName, len(changes.Skipped))
+
+	if len(changes.Delete) > 0 {
+		slog.InfoContext(ctx, "Deleting replaced role bindings", "roleBindings", len(changes.Delete))
+
+		if err := applyObjects(ctx, "add_members", changes.Delete, remove); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete replaced roles", "error", err, "roleBindings", len(changes.Delete))
+			response.Success = false
+			response.Code = codeRolesNotReplaced
+			response.Message = fmt.Sprintf("%s, but failed to delete %d lower roles they replace, so those users hold both roles until the request is retried: %v", message, len(changes.Delete), err)
+			response.NotReplaced = changes.Replaced
+			return respondLambdaJSON(http.StatusBadGateway, response)
+		}
+		message += fmt.Sprintf(", replacing %d lower roles", len(changes.Replaced))
+		response.Replaced = changes.Replaced
+	}
+
+	response.Message = message
+	return respondLambdaJSON(http.StatusOK, response)
+}
+
+// Error codes of the revoke-member endpoint
+const (
+	codeMemberNotFound   = "member_not_found"   // The user holds no role on the project
+	codeLastProjectOwner = "last_project_owner" // Removing the user would leave the project without a project-owner
+)
+
+// RemoveMemberResponse defines the response for the revoke-member endpoint
+type RemoveMemberResponse struct {
+	Response
+	Removed []RoleBindingDetails `json:"removed"` // Role bindings that were deleted
+}
+
+// selectMemberRoleBindings returns the role bindings held by the user and whether deleting
+// them would leave the project without any project-owner
+func selectMemberRoleBindings(roleBindings []v1alphaRoleBinding.RoleBinding, userID string) ([]v1alphaRoleBinding.RoleBinding, bool) {
+	var matching []v1alphaRoleBinding.RoleBinding
+	remainingOwners := 0
+	removesOwner := false
+
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Spec.User != nil && *roleBinding.Spec.User == userID {
+			matching = append(matching, roleBinding)
+			if roleBinding.Spec.RoleRef == "project-owner" {
+				removesOwner = true
+			}
+			continue
+		}
+		if roleBinding.Spec.RoleRef == "project-owner" {
+			remainingOwners++
+		}
+	}
+
+	return matching, removesOwner && remainingOwners == 0
+}
+
+// handleRemoveMember deletes every role binding a user holds on a project
+func handleRemoveMember(ctx context.Context, request events.APIGatewayProxyRequest, projectName, userIdentifier string) (events.APIGatewayProxyResponse, error) {
+	// Only allow DELETE requests
+	if request.HTTPMethod != "DELETE" {
+		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
+	}
+
+	slog.InfoContext(ctx, "Processing remove member request", "user", userIdentifier)
+
+	// Both path segments are validated together, like the fields of a request body
+	var fieldErrors []FieldError
+	if err := validateProjectName(projectName); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "name", Code: validationErrorCode(err), Message: err.Error(), Value: projectName})
+	}
+	if err := validateUserIdentifier(userIdentifier); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "user", Code: validationErrorCode(err), Message: err.Error(), Value: userIdentifier})
+	}
+	if len(fieldErrors) > 0 {
+		return respondLambdaError(http.StatusBadRequest, codeValidationFailed, validationSummary(fieldErrors), fieldErrors)
+	}
+
+	// The project is checked before Nobl9 is asked about the user or its members
+	if err := authorize(ctx, authzRequest{Project: projectName}); err != nil {
+		return respondLambdaError(http.StatusForbidden, codeForbidden, err.Error(), nil)
+	}
+
+	// Create a context with timeout for all SDK operations
+	sdkCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	client, err := getNobl9Client(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to initialize Nobl9 client", "error", err)
+		return respondLambdaWithStatus(http.StatusInternalServerError, false, err.Error())
+	}
+
+	userID, err := resolveUserID(sdkCtx, client, userIdentifier)
+	if err != nil {
+		slog.InfoContext(ctx, "Failed to look up user", "user", userIdentifier, "error", err)
+		if isLookupUpstreamFailure(err) {
+			return respondNobl9Error(err, "Failed to look up users in Nobl9")
+		}
+		return respondLambdaError(http.StatusNotFound, codeUserNotFound, capitalizeMessage(err.Error()), nil)
+	}
+
+	roleBindings, err := getProjectRoleBindings(sdkCtx, client, projectName)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to retrieve role bindings", "error", err)
+		return respondNobl9Error(err, fmt.Sprintf("Failed to retrieve role bindings for project '%s'", projectName))
+	}
+
+	userRoleBindings, removesLastOwner := selectMemberRoleBindings(roleBindings, userID)
+	if len(userRoleBindings) == 0 {
+		return respondLambdaError(http.StatusNotFound, codeMemberNotFound, fmt.Sprintf("User '%s' has no role bindings on project '%s'", userIdentifier, projectName), nil)
+	}
+	if removesLastOwner {
+		slog.InfoContext(ctx, "Refusing to remove the last project-owner", "user", userIdentifier)
+		return respondLambdaError(http.StatusConflict, codeLastProjectOwner, fmt.Sprintf("Cannot remove '%s' because they are the last project-owner of project '%s'", userIdentifier, projectName), nil)
+	}
+
+	// Callers may only revoke roles they could grant
+	var roles []string
+	for _, roleBinding := range userRoleBindings {
+		roles = append(roles, roleBinding.Spec.RoleRef)
+	}
+	if err := authorize(ctx, authzRequest{Project: projectName, Roles: roles}); err != nil {
+		return respondLambdaError(http.StatusForbidden, codeForbidden, err.Error(), nil)
+	}
+
+	objects := make([]manifest.Object, 0, len(userRoleBindings))
+	removed := make([]RoleBindingDetails, 0, len(userRoleBindings))
+	for _, roleBinding := range userRoleBindings {
+		objects = append(objects, roleBinding)
+		details := RoleBindingDetails{Name: roleBinding.Metadata.Name, Role: roleBinding.Spec.RoleRef, UserID: userID}
+		if strings.Contains(userIdentifier, "@") {
+			details.Email = userIdentifier
+		}
+		removed = append(removed, details)
+	}
+
+	slog.InfoContext(ctx, "Deleting role bindings", "roleBindings", len(objects), "userId", userID)
+
+	if err := applyObjects(sdkCtx, "remove_member", objects, client.Objects().V1().Delete); err != nil {
+		slog.ErrorContext(ctx, "Failed to remove roles", "error", err)
+		return respondNobl9Error(err, fmt.Sprintf("Failed to remove roles on project '%s'", projectName))
+	}
+
+	message := fmt.Sprintf("Removed %d role bindings for '%s' from project '%s'", len(removed), userIdentifier, projectName)
+	return respondLambdaJSON(http.StatusOK, RemoveMemberResponse{
+		Response: Response{
+			Success: true,
+			Message: message,
+		},
+		Removed: removed,
+	})
+}
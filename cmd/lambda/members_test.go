@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nobl9/nobl9-go/manifest"
+	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
+	"github.com/nobl9/nobl9-go/sdk"
+)
+
+func TestExistingMemberships(t *testing.T) {
+	roleBindings := []v1alphaRoleBinding.RoleBinding{
+		v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: "owner-binding"},
+			v1alphaRoleBinding.Spec{User: ptr("user-1"), RoleRef: "project-owner", ProjectRef: "my-project"},
+		),
+		v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: "group-binding"},
+			v1alphaRoleBinding.Spec{GroupRef: ptr("sre"), RoleRef: "project-viewer", ProjectRef: "my-project"},
+		),
+	}
+
+	memberships := existingMemberships(roleBindings)
+
+	if len(memberships) != 1 {
+		t.Errorf("existingMemberships() returned %d users, want 1", len(memberships))
+	}
+
+	if held := memberships["user-1"]; len(held) != 1 || held[0].Metadata.Name != "owner-binding" {
+		t.Errorf("existingMemberships() user-1 = %+v, want the owner binding", held)
+	}
+}
+
+func TestPlanMemberChanges(t *testing.T) {
+	binding := func(name, userID, role string) v1alphaRoleBinding.RoleBinding {
+		return v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: name},
+			v1alphaRoleBinding.Spec{User: ptr(userID), RoleRef: role, ProjectRef: "my-project"},
+		)
+	}
+	memberships := existingMemberships([]v1alphaRoleBinding.RoleBinding{
+		binding("alice-viewer", "00u1", "project-viewer"),
+		binding("bob-owner", "00u2", "project-owner"),
+		binding("carol-editor", "00u3", "project-editor"),
+	})
+	assignments := []userAssignment{
+		{Identifier: "alice@example.com", UserID: "00u1", Role: "project-owner", Field: "userGroups[0].userIds[0]"},
+		{Identifier: "bob@example.com", UserID: "00u2", Role: "project-viewer", Field: "userGroups[1].userIds[0]"},
+		{Identifier: "00u3", UserID: "00u3", Role: "project-editor", Field: "userGroups[2].userIds[0]"},
+		{Identifier: "dave@example.com", UserID: "00u4", Role: "project-editor", Field: "userGroups[2].userIds[1]"},
+	}
+
+	changes := planMemberChanges("my-project", assignments, memberships, false)
+
+	// Alice's viewer role is upgraded to owner rather than kept alongside it
+	if len(changes.Replaced) != 1 || changes.Replaced[0].Name != "alice-viewer" || len(changes.Delete) != 1 {
+		t.Errorf("replaced = %+v, want alice's viewer binding", changes.Replaced)
+	}
+	var added []string
+	for _, details := range changes.Added {
+		added = append(added, details.UserID+" "+details.Role)
+	}
+	if !reflect.DeepEqual(added, []string{"00u1 project-owner", "00u4 project-editor"}) || len(changes.Apply) != 2 {
+		t.Errorf("added = %v", added)
+	}
+
+	// Bob keeps the higher owner role and Carol already holds the editor role
+	var skipped []string
+	for _, details := range changes.Skipped {
+		skipped = append(skipped, details.Name)
+	}
+	if !reflect.DeepEqual(skipped, []string{"bob-owner", "carol-editor"}) {
+		t.Errorf("skipped = %v", skipped)
+	}
+	if len(changes.Conflicts) != 0 {
+		t.Errorf("conflicts = %+v, want none when merging", changes.Conflicts)
+	}
+
+	// Rejecting duplicates reports users holding a different role
+	changes = planMemberChanges("my-project", assignments, memberships, true)
+	var fields []string
+	for _, conflict := range changes.Conflicts {
+		if conflict.Code != codeConflictingRoles {
+			t.Errorf("conflict code = %q", conflict.Code)
+		}
+		fields = append(fields, conflict.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"userGroups[0].userIds[0]", "userGroups[1].userIds[0]"}) {
+		t.Errorf("conflicts = %+v", changes.Conflicts)
+	}
+}
+
+func TestPlanMemberChangesFinishesReplacement(t *testing.T) {
+	// Alice kept their viewer role because an earlier request could not delete it
+	memberships := existingMemberships([]v1alphaRoleBinding.RoleBinding{
+		v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: "alice-viewer"},
+			v1alphaRoleBinding.Spec{User: ptr("00u1"), RoleRef: "project-viewer", ProjectRef: "my-project"},
+		),
+		v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: "alice-owner"},
+			v1alphaRoleBinding.Spec{User: ptr("00u1"), RoleRef: "project-owner", ProjectRef: "my-project"},
+		),
+	})
+	assignments := []userAssignment{{Identifier: "alice@example.com", UserID: "00u1", Role: "project-owner", Field: "userGroups[0].userIds[0]"}}
+
+	changes := planMemberChanges("my-project", assignments, memberships, false)
+
+	if len(changes.Apply) != 0 || len(changes.Skipped) != 1 || changes.Skipped[0].Name != "alice-owner" {
+		t.Errorf("changes = %+v, want the owner role skipped", changes)
+	}
+	if len(changes.Delete) != 1 || changes.Replaced[0].Name != "alice-viewer" {
+		t.Errorf("replaced = %+v, want the leftover viewer binding deleted", changes.Replaced)
+	}
+}
+
+func TestApplyMemberChangesDeleteFails(t *testing.T) {
+	captureMetrics(t)
+
+	lower := v1alphaRoleBinding.New(
+		v1alphaRoleBinding.Metadata{Name: "alice-viewer"},
+		v1alphaRoleBinding.Spec{User: ptr("00u1"), RoleRef: "project-viewer", ProjectRef: "my-project"},
+	)
+	changes := planMemberChanges("my-project",
+		[]userAssignment{{Identifier: "alice@example.com", UserID: "00u1", Role: "project-owner", Field: "userGroups[0].userIds[0]"}},
+		existingMemberships([]v1alphaRoleBinding.RoleBinding{lower}), false)
+
+	var applied int
+	apply := func(ctx context.Context, objects []manifest.Object) error {
+		applied += len(objects)
+		return nil
+	}
+	remove := func(ctx context.Context, objects []manifest.Object) error {
+		return &sdk.HTTPError{StatusCode: http.StatusServiceUnavailable}
+	}
+
+	response, err := applyMemberChanges(context.Background(), "my-project", changes, nil, apply, remove)
+	if err != nil || applied != 1 {
+		t.Fatalf("applyMemberChanges() = %v, applied %d role bindings", err, applied)
+	}
+
+	// The new role was granted, but Alice still holds the lower one as well
+	var body AddMembersResponse
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusBadGateway || body.Success || body.Code != codeRolesNotReplaced {
+		t.Errorf("response = %d %s, want a %s failure", response.StatusCode, response.Body, codeRolesNotReplaced)
+	}
+	if len(body.Added) != 1 || body.Added[0].Role != "project-owner" {
+		t.Errorf("added = %+v, want the owner role", body.Added)
+	}
+	if len(body.NotReplaced) != 1 || body.NotReplaced[0].Name != "alice-viewer" || len(body.Replaced) != 0 {
+		t.Errorf("notReplaced = %+v, replaced = %+v, want the viewer binding not replaced", body.NotReplaced, body.Replaced)
+	}
+	if isReplayableResponse(response) {
+		t.Error("a partially applied response would be replayed instead of retried")
+	}
+}
+
+func TestHandleAddMembersValidation(t *testing.T) {
+	// Test invalid method
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/api/projects/valid-project/members",
+		Body:       `{"userGroups": [{"userIds": "user@example.com", "role": "project-viewer"}]}`,
+	}
+
+	response, err := handleAddMembers(context.Background(), request, "valid-project")
+	if err != nil {
+		t.Errorf("handleAddMembers() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("handleAddMembers() status = %d, want %d", response.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	// Test invalid JSON
+	request.HTTPMethod = "POST"
+	request.Body = `{"invalid": json}`
+	response, err = handleAddMembers(context.Background(), request, "valid-project")
+	if err != nil {
+		t.Errorf("handleAddMembers() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("handleAddMembers() status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+
+	// Test missing user groups
+	request.Body = `{"userGroups": []}`
+	response, err = handleAddMembers(context.Background(), request, "valid-project")
+	if err != nil {
+		t.Errorf("handleAddMembers() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("handleAddMembers() status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+
+	// Test invalid role
+	request.Body = `{"userGroups": [{"userIds": "user@example.com", "role": "invalid-role"}]}`
+	response, err = handleAddMembers(context.Background(), request, "valid-project")
+	if err != nil {
+		t.Errorf("handleAddMembers() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("handleAddMembers() status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+
+	// Test invalid project name
+	request.Body = `{"userGroups": [{"userIds": "user@example.com", "role": "project-viewer"}]}`
+	response, err = handleAddMembers(context.Background(), request, "ab")
+	if err != nil {
+		t.Errorf("handleAddMembers() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("handleAddMembers() status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+
+	// Test valid input routed through handleRequest but missing credentials (should return 500)
+	response, err = handleRequest(context.Background(), request)
+	if err != nil {
+		t.Errorf("handleRequest() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("handleRequest() status = %d, want %d", response.StatusCode, http.StatusInternalServerError)
+	}
+}
+
+func TestSelectMemberRoleBindings(t *testing.T) {
+	ownerBinding := v1alphaRoleBinding.New(
+		v1alphaRoleBinding.Metadata{Name: "owner-binding"},
+		v1alphaRoleBinding.Spec{User: ptr("user-1"), RoleRef: "project-owner", ProjectRef: "my-project"},
+	)
+	editorBinding := v1alphaRoleBinding.New(
+		v1alphaRoleBinding.Metadata{Name: "editor-binding"},
+		v1alphaRoleBinding.Spec{User: ptr("user-2"), RoleRef: "project-editor", ProjectRef: "my-project"},
+	)
+	secondOwnerBinding := v1alphaRoleBinding.New(
+		v1alphaRoleBinding.Metadata{Name: "second-owner-binding"},
+		v1alphaRoleBinding.Spec{User: ptr("user-3"), RoleRef: "project-owner", ProjectRef: "my-project"},
+	)
+
+	// Removing a non-owner is always allowed
+	matching, removesLastOwner := selectMemberRoleBindings([]v1alphaRoleBinding.RoleBinding{ownerBinding, editorBinding}, "user-2")
+	if len(matching) != 1 || matching[0].Metadata.Name != "editor-binding" || removesLastOwner {
+		t.Errorf("selectMemberRoleBindings(user-2) = %v, %v", matching, removesLastOwner)
+	}
+
+	// Removing the only owner is refused
+	matching, removesLastOwner = selectMemberRoleBindings([]v1alphaRoleBinding.RoleBinding{ownerBinding, editorBinding}, "user-1")
+	if len(matching) != 1 || !removesLastOwner {
+		t.Errorf("selectMemberRoleBindings(user-1) = %v, %v, want last owner", matching, removesLastOwner)
+	}
+
+	// Removing one of several owners is allowed
+	matching, removesLastOwner = selectMemberRoleBindings([]v1alphaRoleBinding.RoleBinding{ownerBinding, secondOwnerBinding}, "user-1")
+	if len(matching) != 1 || removesLastOwner {
+		t.Errorf("selectMemberRoleBindings(user-1) with second owner = %v, %v", matching, removesLastOwner)
+	}
+
+	// Unknown users have no bindings
+	matching, _ = selectMemberRoleBindings([]v1alphaRoleBinding.RoleBinding{ownerBinding}, "user-9")
+	if len(matching) != 0 {
+		t.Errorf("selectMemberRoleBindings(user-9) = %v, want none", matching)
+	}
+}
+
+func TestHandleRemoveMemberValidation(t *testing.T) {
+	// Test invalid method
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/projects/valid-project/members/user@example.com",
+	}
+
+	response, err := handleRemoveMember(context.Background(), request, "valid-project", "user@example.com")
+	if err != nil {
+		t.Errorf("handleRemoveMember() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("handleRemoveMember() status = %d, want %d", response.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	// Invalid path segments are reported with stable codes
+	request.HTTPMethod = "DELETE"
+	tests := []struct {
+		projectName    string
+		userIdentifier string
+		field          string
+		code           string
+	}{
+		{"valid-project", "invalid-email@", "user", codeInvalidEmail},
+		{"valid-project", "a@example.com,b@example.com", "user", codeInvalidFormat},
+		{"valid-project", "x", "user", codeInvalidUserID},
+		{"ab", "user@example.com", "name", codeTooShort},
+	}
+	for _, tt := range tests {
+		response, err = handleRemoveMember(context.Background(), request, tt.projectName, tt.userIdentifier)
+		if err != nil {
+			t.Errorf("handleRemoveMember() error = %v", err)
+		}
+
+		var body Response
+		if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		if response.StatusCode != http.StatusBadRequest || body.Code != codeValidationFailed || len(body.Errors) != 1 ||
+			body.Errors[0].Field != tt.field || body.Errors[0].Code != tt.code {
+			t.Errorf("handleRemoveMember(%s, %s) = %d %s, want %s on %s", tt.projectName, tt.userIdentifier, response.StatusCode, response.Body, tt.code, tt.field)
+		}
+	}
+
+	// Test valid input routed through handleRequest but missing credentials (should return 500)
+	request.Path = "/api/projects/valid-project/members/user@example.com"
+	response, err = handleRequest(context.Background(), request)
+	if err != nil {
+		t.Errorf("handleRequest() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("handleRequest() status = %d, want %d", response.StatusCode, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// defaultMetricsNamespace is the CloudWatch namespace metrics are published under
+const defaultMetricsNamespace = "Nobl9Wizard"
+
+// Units of CloudWatch metrics
+const (
+	unitCount        = "Count"
+	unitMilliseconds = "Milliseconds"
+)
+
+// metrics publishes onboarding metrics for the lifetime of the container.
+// setupMetrics replaces it with one configured from the environment.
+var metrics = newMetricsEmitter(os.Stderr, defaultMetricsNamespace, true)
+
+// metric is a single named value of a metrics line
+type metric struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+// countMetric is a metric counting n occurrences
+func countMetric(name string, n int) metric {
+	return metric{Name: name, Unit: unitCount, Value: float64(n)}
+}
+
+// flagMetric is a count metric that is 1 if set and 0 otherwise, so its average is a rate
+func flagMetric(name string, set bool) metric {
+	if set {
+		return countMetric(name, 1)
+	}
+	return countMetric(name, 0)
+}
+
+// latencyMetric is a metric measuring a duration in milliseconds
+func latencyMetric(name string, duration time.Duration) metric {
+	return metric{Name: name, Unit: unitMilliseconds, Value: float64(duration.Microseconds()) / 1000}
+}
+
+// metricsEmitter writes metrics as CloudWatch Embedded Metric Format (EMF) log lines. CloudWatch Logs
+// extracts the metrics from the lines, so no agent or metrics API call is needed. It is safe for concurrent use.
+type metricsEmitter struct {
+	mu        sync.Mutex
+	out       io.Writer
+	namespace string
+	enabled   bool
+	now       func() time.Time
+}
+
+// newMetricsEmitter creates an emitter writing EMF lines for the namespace to out
+func newMetricsEmitter(out io.Writer, namespace string, enabled bool) *metricsEmitter {
+	return &metricsEmitter{
+		out:       out,
+		namespace: namespace,
+		enabled:   enabled,
+		now:       time.Now,
+	}
+}
+
+// Emit writes one EMF line with the metrics, split by the given dimensions
+func (e *metricsEmitter) Emit(dimensions map[string]string, values ...metric) {
+	if !e.enabled || len(values) == 0 {
+		return
+	}
+
+	dimensionNames := make([]string, 0, len(dimensions))
+	for name := range dimensions {
+		dimensionNames = append(dimensionNames, name)
+	}
+	sort.Strings(dimensionNames)
+
+	definitions := make([]map[string]string, len(values))
+	line := make(map[string]interface{}, len(dimensions)+len(values)+1)
+	for name, value := range dimensions {
+		line[name] = value
+	}
+	for i, m := range values {
+		definitions[i] = map[string]string{"Name": m.Name, "Unit": m.Unit}
+		line[m.Name] = m.Value
+	}
+	line["_aws"] = map[string]interface{}{
+		"Timestamp": e.now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{{
+			"Namespace":  e.namespace,
+			"Dimensions": [][]string{dimensionNames},
+			"Metrics":    definitions,
+		}},
+	}
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		slog.Error("Failed to encode metrics", "error", err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.out.Write(append(encoded, '\n'))
+}
+
+// metricsRoute names the route of a request path without project or user names, so routes stay a small set of dimension values
+func metricsRoute(path string) string {
+	switch path {
+	case "/health", "/api/create-project", batchProjectsPath, importProjectsPath, userLookupPath:
+		return path
+	}
+
+	if _, rest, ok := parseProjectPath(path); ok {
+		switch {
+		case len(rest) == 0:
+			return "/api/projects/{name}"
+		case len(rest) == 1 && rest[0] == "members":
+			return "/api/projects/{name}/members"
+		case len(rest) == 2 && rest[0] == "members":
+			return "/api/projects/{name}/members/{user}"
+		}
+	}
+	return "other"
+}
+
+// responseOutcome returns the error code of a failed response, "ok" for other successful responses,
+// or "http_<status>" for failures without a code
+func responseOutcome(response events.APIGatewayProxyResponse) string {
+	var body Response
+	json.Unmarshal([]byte(response.Body), &body)
+
+	switch {
+	case body.Code != "":
+		return body.Code
+	case response.StatusCode < 400:
+		return "ok"
+	default:
+		return "http_" + strconv.Itoa(response.StatusCode)
+	}
+}
+
+// recordRequest counts a request by route and outcome code and records its latency
+func recordRequest(request events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse, err error, duration time.Duration) {
+	outcome := "error"
+	if err == nil {
+		outcome = responseOutcome(response)
+	}
+	metrics.Emit(
+		map[string]string{"Route": metricsRoute(request.Path), "Outcome": outcome},
+		countMetric("Requests", 1),
+		latencyMetric("RequestLatency", duration),
+	)
+}
+
+// recordProjectResult counts a create project pipeline result by status, and the users given roles on created projects
+func recordProjectResult(result createProjectResult) {
+	results := []metric{countMetric("Projects", 1)}
+	if result.Status == createStatusCreated {
+		results = append(results, countMetric("UsersPerProject", max(len(result.Objects)-1, 0)))
+	}
+	metrics.Emit(map[string]string{"Outcome": result.Status}, results...)
+}
+
+// recordUserLookup records a Nobl9 user lookup: whether it was served from the cache, how long it took and how it failed
+func recordUserLookup(cacheHit bool, duration time.Duration, notFound, failed bool) {
+	if cacheHit {
+		metrics.Emit(nil, flagMetric("UserLookupCacheHits", true))
+		return
+	}
+	metrics.Emit(nil,
+		flagMetric("UserLookupCacheHits", false),
+		latencyMetric("UserLookupLatency", duration),
+		flagMetric("UserLookupNotFound", notFound),
+		flagMetric("UserLookupFailures", failed),
+	)
+}
+
+// recordApply records how long applying or deleting objects in Nobl9 took, and whether it failed
+func recordApply(operation string, duration time.Duration, err error) {
+	metrics.Emit(map[string]string{"Operation": operation},
+		latencyMetric("ApplyLatency", duration),
+		flagMetric("ApplyFailures", err != nil),
+	)
+}
+
+// recordCredentialFetch records a Nobl9 client cache lookup, with the credential fetch latency on a miss
+func recordCredentialFetch(cacheHit bool, duration time.Duration, err error) {
+	if cacheHit {
+		metrics.Emit(nil, flagMetric("CredentialCacheHits", true))
+		return
+	}
+	metrics.Emit(nil,
+		flagMetric("CredentialCacheHits", false),
+		latencyMetric("CredentialFetchLatency", duration),
+		flagMetric("CredentialFetchFailures", err != nil),
+	)
+}
+
+// metricsNamespaceFromEnv reads the CloudWatch namespace from METRICS_NAMESPACE
+func metricsNamespaceFromEnv() string {
+	if namespace := strings.TrimSpace(os.Getenv("METRICS_NAMESPACE")); namespace != "" {
+		return namespace
+	}
+	return defaultMetricsNamespace
+}
+
+// metricsEnabledFromEnv reads whether metrics are emitted from METRICS_ENABLED, which defaults to true
+func metricsEnabledFromEnv() bool {
+	value := os.Getenv("METRICS_ENABLED")
+	if value == "" {
+		return true
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid METRICS_ENABLED, using default", "value", value, "default", true)
+		return true
+	}
+	return enabled
+}
+
+// setupMetrics configures the metrics emitter from METRICS_NAMESPACE and METRICS_ENABLED.
+// It runs after setupLogging, so invalid settings are reported by the structured logger.
+func setupMetrics() {
+	metrics = newMetricsEmitter(os.Stderr, metricsNamespaceFromEnv(), metricsEnabledFromEnv())
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// captureMetrics makes a buffered emitter the package emitter for the rest of the test
+func captureMetrics(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := metrics
+	metrics = newMetricsEmitter(&buf, defaultMetricsNamespace, true)
+	t.Cleanup(func() { metrics = previous })
+	return &buf
+}
+
+// decodeMetricLines parses the EMF lines written to buf
+func decodeMetricLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("metrics line is not JSON: %q", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestMetricsEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	emitter := newMetricsEmitter(&buf, "Test", true)
+	emitter.now = func() time.Time { return time.UnixMilli(1700000000000) }
+
+	emitter.Emit(map[string]string{"Route": "/health", "Outcome": "ok"},
+		countMetric("Requests", 1),
+		latencyMetric("RequestLatency", 1500*time.Microsecond),
+	)
+
+	expected := `{"Outcome":"ok","RequestLatency":1.5,"Requests":1,"Route":"/health","_aws":{"CloudWatchMetrics":[{"Dimensions":[["Outcome","Route"]],"Metrics":[{"Name":"Requests","Unit":"Count"},{"Name":"RequestLatency","Unit":"Milliseconds"}],"Namespace":"Test"}],"Timestamp":1700000000000}}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("Emit() wrote\n%s\nwant\n%s", buf.String(), expected)
+	}
+
+	// Metrics without dimensions use a single empty dimension set
+	buf.Reset()
+	emitter.Emit(nil, flagMetric("CredentialCacheHits", false))
+	if !strings.Contains(buf.String(), `"Dimensions":[[]]`) || !strings.Contains(buf.String(), `"CredentialCacheHits":0`) {
+		t.Errorf("Emit() without dimensions wrote %s", buf.String())
+	}
+
+	// Disabled emitters write nothing
+	buf.Reset()
+	newMetricsEmitter(&buf, "Test", false).Emit(nil, countMetric("Requests", 1))
+	if buf.Len() != 0 {
+		t.Errorf("disabled Emit() wrote %s", buf.String())
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/health", "/health"},
+		{"/api/create-project", "/api/create-project"},
+		{"/api/projects:batch", "/api/projects:batch"},
+		{"/api/projects/payments", "/api/projects/{name}"},
+		{"/api/projects/payments/members", "/api/projects/{name}/members"},
+		{"/api/projects/payments/members/alice@example.com", "/api/projects/{name}/members/{user}"},
+		{"/api/projects/payments/other", "other"},
+		{"/wp-admin", "other"},
+	}
+
+	for _, tt := range tests {
+		if got := metricsRoute(tt.path); got != tt.expected {
+			t.Errorf("metricsRoute(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestResponseOutcome(t *testing.T) {
+	tests := []struct {
+		response events.APIGatewayProxyResponse
+		expected string
+	}{
+		{events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: `{"success":true}`}, "ok"},
+		{events.APIGatewayProxyResponse{StatusCode: http.StatusMultiStatus, Body: `{"success":false}`}, "ok"},
+		{events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest, Body: `{"success":false,"code":"validation_failed"}`}, "validation_failed"},
+		{events.APIGatewayProxyResponse{StatusCode: http.StatusNotFound, Body: `{"success":false}`}, "http_404"},
+		{events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "Internal server error"}, "http_500"},
+	}
+
+	for _, tt := range tests {
+		if got := responseOutcome(tt.response); got != tt.expected {
+			t.Errorf("responseOutcome(%d %s) = %q, want %q", tt.response.StatusCode, tt.response.Body, got, tt.expected)
+		}
+	}
+}
+
+func TestHandleRequestMetrics(t *testing.T) {
+	buf := captureMetrics(t)
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/create-project",
+		Body:       `{"appID":"payments","userGroups":[]}`,
+	}
+	if _, err := handleRequest(context.Background(), request); err != nil {
+		t.Fatalf("handleRequest() error = %v", err)
+	}
+
+	lines := decodeMetricLines(t, buf)
+	var projects, requests map[string]interface{}
+	for _, line := range lines {
+		if _, ok := line["Projects"]; ok {
+			projects = line
+		}
+		if _, ok := line["Requests"]; ok {
+			requests = line
+		}
+	}
+
+	if projects == nil || projects["Outcome"] != createStatusValidationFailed || projects["UsersPerProject"] != nil {
+		t.Errorf("project metrics = %v, want a validation_failed project without users", projects)
+	}
+	if requests == nil || requests["Route"] != "/api/create-project" || requests["Outcome"] != codeValidationFailed {
+		t.Errorf("request metrics = %v, want the route and validation_failed outcome", requests)
+	}
+}
+
+func TestCredentialAndLookupMetrics(t *testing.T) {
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+	buf := captureMetrics(t)
+
+	cache := newNobl9ClientCache(newNobl9Client, time.Minute)
+	cache.Get(context.Background())
+	users := &fakeUsers{users: map[string]string{"alice@example.com": "00u1"}}
+	lookupCache := newUserLookupCache(time.Minute)
+	lookupUser(context.Background(), lookupCache, users.GetUser, "alice@example.com")
+	lookupUser(context.Background(), lookupCache, users.GetUser, "alice@example.com")
+	lookupUser(context.Background(), lookupCache, users.GetUser, "error@example.com")
+
+	lines := decodeMetricLines(t, buf)
+	if len(lines) != 4 {
+		t.Fatalf("emitted %d metrics lines, want 4: %s", len(lines), buf.String())
+	}
+	if lines[0]["CredentialCacheHits"] != float64(0) || lines[0]["CredentialFetchFailures"] != float64(1) {
+		t.Errorf("credential metrics = %v, want a failed fetch", lines[0])
+	}
+	if lines[1]["UserLookupCacheHits"] != float64(0) || lines[1]["UserLookupFailures"] != float64(0) {
+		t.Errorf("lookup metrics = %v, want an uncached lookup", lines[1])
+	}
+	if lines[2]["UserLookupCacheHits"] != float64(1) {
+		t.Errorf("lookup metrics = %v, want a cache hit", lines[2])
+	}
+	if lines[3]["UserLookupFailures"] != float64(1) {
+		t.Errorf("lookup metrics = %v, want a failure", lines[3])
+	}
+}
+
+func TestMetricsEnabledFromEnv(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"", true},
+		{"true", true},
+		{"false", false},
+		{"0", false},
+		{"sometimes", true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("METRICS_ENABLED", tt.value)
+		if got := metricsEnabledFromEnv(); got != tt.expected {
+			t.Errorf("metricsEnabledFromEnv(%q) = %v, want %v", tt.value, got, tt.expected)
+		}
+	}
+}
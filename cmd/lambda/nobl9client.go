@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nobl9/nobl9-go/manifest"
+	"github.com/nobl9/nobl9-go/sdk"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// defaultNobl9CredentialsTTL is how long cached Nobl9 credentials and the SDK client are reused
+const defaultNobl9CredentialsTTL = time.Hour
+
+// nobl9Clients holds the Nobl9 SDK client shared by all invocations of a warm container.
+// initialize replaces it with one using NOBL9_CREDENTIALS_TTL.
+var nobl9Clients = newNobl9ClientCache(newNobl9Client, defaultNobl9CredentialsTTL)
+
+// nobl9ClientCache lazily builds a Nobl9 SDK client and reuses it until its credentials expire
+// or Nobl9 rejects them. It is safe for concurrent use.
+type nobl9ClientCache struct {
+	mu        sync.Mutex
+	newClient func(ctx context.Context) (*sdk.Client, error) // Fetches credentials and builds a client
+	ttl       time.Duration                                  // How long a client is reused before credentials are refreshed
+	now       func() time.Time
+	client    *sdk.Client
+	expiresAt time.Time
+}
+
+// newNobl9ClientCache creates a client cache using the given client factory
+func newNobl9ClientCache(newClient func(ctx context.Context) (*sdk.Client, error), ttl time.Duration) *nobl9ClientCache {
+	return &nobl9ClientCache{
+		newClient: newClient,
+		ttl:       ttl,
+		now:       time.Now,
+	}
+}
+
+// Get returns the cached client, building a new one with fresh credentials if none is cached or it has expired.
+// Concurrent callers wait for a single refresh instead of each fetching credentials.
+func (c *nobl9ClientCache) Get(ctx context.Context) (*sdk.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil && c.now().Before(c.expiresAt) {
+		recordCredentialFetch(true, 0, nil)
+		return c.client, nil
+	}
+
+	slog.InfoContext(ctx, "Refreshing Nobl9 credentials and SDK client")
+	start := time.Now()
+	client, err := c.newClient(ctx)
+	recordCredentialFetch(false, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	c.client = client
+	c.expiresAt = c.now().Add(c.ttl)
+	return client, nil
+}
+
+// Invalidate drops the cached client so the next Get fetches fresh credentials
+func (c *nobl9ClientCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client != nil {
+		slog.Info("Invalidating cached Nobl9 SDK client")
+	}
+	c.client = nil
+}
+
+// invalidateIfUnauthorized drops the cached client when Nobl9 rejected its credentials, which may have
+// been rotated or revoked, so the next request fetches fresh ones. It is called wherever the SDK is used,
+// so every entry point, including batch, import and the CLI, recovers without a container restart.
+func invalidateIfUnauthorized(err error) {
+	if err != nil && classifyNobl9Error(err).Code == codeNobl9Unauthorized {
+		nobl9Clients.Invalidate()
+	}
+}
+
+// getNobl9Client returns the Nobl9 SDK client shared across warm invocations
+func getNobl9Client(ctx context.Context) (*sdk.Client, error) {
+	return nobl9Clients.Get(ctx)
+}
+
+// applyObjects applies or deletes objects in Nobl9 with apply, tracing the call and recording its latency for the operation
+func applyObjects(ctx context.Context, operation string, objects []manifest.Object, apply func(ctx context.Context, objects []manifest.Object) error) error {
+	ctx, span := startSpan(ctx, "Nobl9 "+operation,
+		attribute.String("nobl9.operation", operation),
+		attribute.Int("nobl9.objects", len(objects)),
+	)
+	start := time.Now()
+	err := apply(ctx, objects)
+	recordApply(operation, time.Since(start), err)
+	invalidateIfUnauthorized(err)
+	endSpan(span, err)
+	return err
+}
+
+// nobl9CredentialsTTLFromEnv reads the credentials cache TTL from NOBL9_CREDENTIALS_TTL
+func nobl9CredentialsTTLFromEnv() time.Duration {
+	value := os.Getenv("NOBL9_CREDENTIALS_TTL")
+	if value == "" {
+		return defaultNobl9CredentialsTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		slog.Warn("Invalid NOBL9_CREDENTIALS_TTL, using default", "value", value, "default", defaultNobl9CredentialsTTL.String())
+		return defaultNobl9CredentialsTTL
+	}
+	return ttl
+}
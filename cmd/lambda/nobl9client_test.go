@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nobl9/nobl9-go/manifest"
+	"github.com/nobl9/nobl9-go/sdk"
+	usersV2 "github.com/nobl9/nobl9-go/sdk/endpoints/users/v2"
+)
+
+func TestNobl9ClientCache(t *testing.T) {
+	calls := 0
+	cache := newNobl9ClientCache(func(ctx context.Context) (*sdk.Client, error) {
+		calls++
+		return &sdk.Client{}, nil
+	}, time.Minute)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	first, err := cache.Get(context.Background())
+	if err != nil || first == nil {
+		t.Fatalf("Get() = %v, %v", first, err)
+	}
+
+	// Warm invocations reuse the client
+	second, _ := cache.Get(context.Background())
+	if second != first || calls != 1 {
+		t.Errorf("Get() within TTL built a new client (calls = %d)", calls)
+	}
+
+	// Expired credentials are refreshed
+	now = now.Add(2 * time.Minute)
+	third, _ := cache.Get(context.Background())
+	if third == first || calls != 2 {
+		t.Errorf("Get() after TTL did not refresh the client (calls = %d)", calls)
+	}
+
+	// Invalidation forces a refresh
+	cache.Invalidate()
+	fourth, _ := cache.Get(context.Background())
+	if fourth == third || calls != 3 {
+		t.Errorf("Get() after Invalidate() did not refresh the client (calls = %d)", calls)
+	}
+}
+
+func TestNobl9ClientCacheError(t *testing.T) {
+	fail := true
+	cache := newNobl9ClientCache(func(ctx context.Context) (*sdk.Client, error) {
+		if fail {
+			return nil, errors.New("parameter not found")
+		}
+		return &sdk.Client{}, nil
+	}, time.Minute)
+
+	if client, err := cache.Get(context.Background()); err == nil || client != nil {
+		t.Errorf("Get() = %v, %v, want error", client, err)
+	}
+
+	// Failures are not cached
+	fail = false
+	if client, err := cache.Get(context.Background()); err != nil || client == nil {
+		t.Errorf("Get() after recovery = %v, %v", client, err)
+	}
+}
+
+func TestNobl9ClientCacheConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	cache := newNobl9ClientCache(func(ctx context.Context) (*sdk.Client, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return &sdk.Client{}, nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	clients := make([]*sdk.Client, 20)
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clients[i], _ = cache.Get(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("concurrent Get() built %d clients, want 1", calls)
+	}
+	for _, client := range clients {
+		if client != clients[0] {
+			t.Errorf("concurrent Get() returned different clients")
+			break
+		}
+	}
+}
+
+func TestNobl9CallsInvalidateRejectedClient(t *testing.T) {
+	previous := nobl9Clients
+	defer func() { nobl9Clients = previous }()
+	captureMetrics(t)
+
+	calls := 0
+	nobl9Clients = newNobl9ClientCache(func(ctx context.Context) (*sdk.Client, error) {
+		calls++
+		return &sdk.Client{}, nil
+	}, time.Hour)
+	nobl9Clients.Get(context.Background())
+
+	failWith := func(statusCode int) func(context.Context, []manifest.Object) error {
+		return func(context.Context, []manifest.Object) error { return &sdk.HTTPError{StatusCode: statusCode} }
+	}
+
+	// Errors unrelated to credentials keep the client
+	applyObjects(context.Background(), "create_project", nil, failWith(http.StatusConflict))
+	nobl9Clients.Get(context.Background())
+	if calls != 1 {
+		t.Errorf("client refreshed after a conflict (calls = %d)", calls)
+	}
+
+	// Rejected credentials are refreshed on the next request, whichever entry point saw the rejection
+	applyObjects(context.Background(), "create_project", nil, failWith(http.StatusUnauthorized))
+	nobl9Clients.Get(context.Background())
+	if calls != 2 {
+		t.Errorf("client not refreshed after a rejected apply (calls = %d)", calls)
+	}
+
+	rejected := func(ctx context.Context, identifier string) (*usersV2.User, error) {
+		return nil, &sdk.HTTPError{StatusCode: http.StatusUnauthorized}
+	}
+	lookupUser(context.Background(), newUserLookupCache(time.Minute), rejected, "alice@example.com")
+	nobl9Clients.Get(context.Background())
+	if calls != 3 {
+		t.Errorf("client not refreshed after a rejected user lookup (calls = %d)", calls)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/nobl9/nobl9-go/sdk"
+)
+
+// Defaults matching the Nobl9 SDK, which only applies them when reading its own config file and environment
+const (
+	defaultNobl9OktaOrgURL     = "https://accounts.nobl9.com"
+	defaultNobl9OktaAuthServer = "auseg9kiegWKEtJZC416"
+	defaultNobl9Timeout        = 10 * time.Second
+)
+
+// Nobl9Config holds everything needed to build a Nobl9 SDK client
+type Nobl9Config struct {
+	ClientID       string        // Nobl9 access key client ID
+	ClientSecret   string        // Nobl9 access key client secret
+	Organization   string        // Organization sent in the Organization header, optional
+	OktaOrgURL     *url.URL      // Base URL of the Okta organization
+	OktaAuthServer string        // ID of the Okta authorization server
+	URL            *url.URL      // Base URL of the Nobl9 API, optional; discovered from the access token if unset
+	Timeout        time.Duration // Timeout of each HTTP request to the Nobl9 API
+}
+
+// nobl9ConfigFromEnv reads the non-secret Nobl9 settings from the environment.
+// Credentials are filled in separately from Parameter Store.
+func nobl9ConfigFromEnv() (Nobl9Config, error) {
+	config := Nobl9Config{
+		Organization:   os.Getenv("NOBL9_ORGANIZATION"),
+		OktaAuthServer: os.Getenv("NOBL9_OKTA_AUTH_SERVER"),
+		Timeout:        defaultNobl9Timeout,
+	}
+	if config.OktaAuthServer == "" {
+		config.OktaAuthServer = defaultNobl9OktaAuthServer
+	}
+
+	oktaOrgURL := os.Getenv("NOBL9_OKTA_ORG_URL")
+	if oktaOrgURL == "" {
+		oktaOrgURL = defaultNobl9OktaOrgURL
+	}
+	parsed, err := parseNobl9URL("NOBL9_OKTA_ORG_URL", oktaOrgURL)
+	if err != nil {
+		return Nobl9Config{}, err
+	}
+	config.OktaOrgURL = parsed
+
+	if apiURL := os.Getenv("NOBL9_URL"); apiURL != "" {
+		if config.URL, err = parseNobl9URL("NOBL9_URL", apiURL); err != nil {
+			return Nobl9Config{}, err
+		}
+	}
+
+	if timeout := os.Getenv("NOBL9_TIMEOUT"); timeout != "" {
+		config.Timeout, err = time.ParseDuration(timeout)
+		if err != nil || config.Timeout <= 0 {
+			return Nobl9Config{}, fmt.Errorf("invalid NOBL9_TIMEOUT '%s': must be a positive duration such as 30s", timeout)
+		}
+	}
+
+	return config, nil
+}
+
+// parseNobl9URL parses an absolute URL from the named setting
+func parseNobl9URL(name, value string) (*url.URL, error) {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid %s '%s': must be an absolute URL", name, value)
+	}
+	return parsed, nil
+}
+
+// sdkConfig converts the config into a Nobl9 SDK config that never reads config files or environment variables
+func (c Nobl9Config) sdkConfig() *sdk.Config {
+	config := &sdk.Config{
+		ClientID:       c.ClientID,
+		ClientSecret:   c.ClientSecret,
+		Project:        sdk.DefaultProject,
+		URL:            c.URL,
+		OktaOrgURL:     c.OktaOrgURL,
+		OktaAuthServer: c.OktaAuthServer,
+		Organization:   c.Organization,
+		Timeout:        c.Timeout,
+	}
+	// Also stops the SDK from persisting access tokens to a config file in $HOME
+	sdk.ConfigOptionNoConfigFile()(config)
+	return config
+}
+
+// newNobl9ClientFromConfig builds a Nobl9 SDK client from explicit configuration
+func newNobl9ClientFromConfig(config Nobl9Config) (*sdk.Client, error) {
+	if config.ClientID == "" || config.ClientSecret == "" {
+		return nil, fmt.Errorf("both Nobl9 client ID and client secret must be provided")
+	}
+	return sdk.NewClient(config.sdkConfig())
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNobl9ConfigFromEnv(t *testing.T) {
+	t.Setenv("NOBL9_ORGANIZATION", "")
+	t.Setenv("NOBL9_OKTA_ORG_URL", "")
+	t.Setenv("NOBL9_OKTA_AUTH_SERVER", "")
+	t.Setenv("NOBL9_URL", "")
+	t.Setenv("NOBL9_TIMEOUT", "")
+
+	// Defaults
+	config, err := nobl9ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("nobl9ConfigFromEnv() error = %v", err)
+	}
+	if config.OktaOrgURL.String() != defaultNobl9OktaOrgURL || config.OktaAuthServer != defaultNobl9OktaAuthServer ||
+		config.URL != nil || config.Organization != "" || config.Timeout != defaultNobl9Timeout {
+		t.Errorf("nobl9ConfigFromEnv() defaults = %+v", config)
+	}
+
+	// Overrides
+	t.Setenv("NOBL9_ORGANIZATION", "my-org")
+	t.Setenv("NOBL9_OKTA_ORG_URL", "https://accounts.example.com")
+	t.Setenv("NOBL9_OKTA_AUTH_SERVER", "auth-server-id")
+	t.Setenv("NOBL9_URL", "https://app.example.com/api")
+	t.Setenv("NOBL9_TIMEOUT", "30s")
+
+	config, err = nobl9ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("nobl9ConfigFromEnv() error = %v", err)
+	}
+	if config.Organization != "my-org" || config.OktaOrgURL.String() != "https://accounts.example.com" ||
+		config.OktaAuthServer != "auth-server-id" || config.URL.String() != "https://app.example.com/api" ||
+		config.Timeout != 30*time.Second {
+		t.Errorf("nobl9ConfigFromEnv() overrides = %+v", config)
+	}
+
+	// Invalid values
+	invalid := []struct {
+		name  string
+		value string
+	}{
+		{"NOBL9_URL", "app.example.com"},
+		{"NOBL9_OKTA_ORG_URL", "://bad"},
+		{"NOBL9_TIMEOUT", "soon"},
+		{"NOBL9_TIMEOUT", "-5s"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			if _, err := nobl9ConfigFromEnv(); err == nil {
+				t.Errorf("nobl9ConfigFromEnv() with %s=%q should fail", tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestNewNobl9ClientFromConfig(t *testing.T) {
+	t.Setenv("NOBL9_ORGANIZATION", "")
+	t.Setenv("NOBL9_OKTA_ORG_URL", "")
+	t.Setenv("NOBL9_OKTA_AUTH_SERVER", "")
+	t.Setenv("NOBL9_URL", "https://app.example.com/api")
+	t.Setenv("NOBL9_TIMEOUT", "")
+	home := os.Getenv("HOME")
+
+	config, err := nobl9ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("nobl9ConfigFromEnv() error = %v", err)
+	}
+	config.ClientID = "client-id"
+	config.ClientSecret = "client-secret"
+
+	client, err := newNobl9ClientFromConfig(config)
+	if err != nil {
+		t.Fatalf("newNobl9ClientFromConfig() error = %v", err)
+	}
+
+	if client.Config.ClientID != "client-id" || client.Config.ClientSecret != "client-secret" ||
+		client.Config.URL.String() != "https://app.example.com/api" || client.Config.Timeout != defaultNobl9Timeout {
+		t.Errorf("newNobl9ClientFromConfig() config = %+v", client.Config)
+	}
+
+	// Building a client must not touch the process environment
+	if os.Getenv("NOBL9_SDK_CLIENT_ID") != "" || os.Getenv("NOBL9_SDK_CLIENT_SECRET") != "" || os.Getenv("HOME") != home {
+		t.Errorf("newNobl9ClientFromConfig() modified the process environment")
+	}
+
+	// Credentials are required
+	config.ClientSecret = ""
+	if _, err := newNobl9ClientFromConfig(config); err == nil {
+		t.Errorf("newNobl9ClientFromConfig() without client secret should fail")
+	}
+}
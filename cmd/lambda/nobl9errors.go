@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nobl9/nobl9-go/sdk"
+)
+
+// Stable error codes returned in Response.Code
+const (
+	codeValidationFailed      = "validation_failed"
+	codeConflict              = "conflict"
+	codeUserNotFound          = "user_not_found"
+	codeNobl9ValidationFailed = "nobl9_validation_failed"
+	codeNobl9Unauthorized     = "nobl9_unauthorized"
+	codeNobl9RateLimited      = "nobl9_rate_limited"
+	codeNobl9Unavailable      = "nobl9_unavailable"
+	codeNobl9Error            = "nobl9_error"
+)
+
+// idpStatusRegex extracts the status code from token errors reported by the Nobl9 SDK,
+// which are not returned as sdk.HTTPError
+var idpStatusRegex = regexp.MustCompile(`IDP replied with \(status: (\d+)\)`)
+
+// nobl9ErrorClass describes how an error returned by the Nobl9 SDK is surfaced to clients
+type nobl9ErrorClass struct {
+	Code       string // Stable error code for the response body
+	StatusCode int    // HTTP status code for the response
+}
+
+// classifyNobl9Error maps an error returned by the Nobl9 SDK to an error class,
+// based on the HTTP status reported by Nobl9 rather than the error message
+func classifyNobl9Error(err error) nobl9ErrorClass {
+	var httpErr *sdk.HTTPError
+	if errors.As(err, &httpErr) {
+		return classifyNobl9StatusCode(httpErr.StatusCode)
+	}
+
+	// Access token requests fail before reaching the API and only report the IDP status in the message
+	if matches := idpStatusRegex.FindStringSubmatch(err.Error()); matches != nil {
+		statusCode, _ := strconv.Atoi(matches[1])
+		return classifyNobl9StatusCode(statusCode)
+	}
+
+	// Timeouts and network failures mean Nobl9 could not be reached
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return nobl9ErrorClass{Code: codeNobl9Unavailable, StatusCode: http.StatusServiceUnavailable}
+	}
+
+	return nobl9ErrorClass{Code: codeNobl9Error, StatusCode: http.StatusInternalServerError}
+}
+
+// classifyNobl9StatusCode maps an HTTP status code returned by Nobl9 to an error class
+func classifyNobl9StatusCode(statusCode int) nobl9ErrorClass {
+	switch {
+	case statusCode == http.StatusConflict:
+		return nobl9ErrorClass{Code: codeConflict, StatusCode: http.StatusConflict}
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusUnprocessableEntity:
+		return nobl9ErrorClass{Code: codeNobl9ValidationFailed, StatusCode: http.StatusUnprocessableEntity}
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return nobl9ErrorClass{Code: codeNobl9Unauthorized, StatusCode: http.StatusBadGateway}
+	case statusCode == http.StatusTooManyRequests:
+		return nobl9ErrorClass{Code: codeNobl9RateLimited, StatusCode: http.StatusTooManyRequests}
+	case statusCode >= 500:
+		return nobl9ErrorClass{Code: codeNobl9Unavailable, StatusCode: http.StatusServiceUnavailable}
+	default:
+		return nobl9ErrorClass{Code: codeNobl9Error, StatusCode: http.StatusInternalServerError}
+	}
+}
+
+// isUpstreamFailure reports whether the error class means Nobl9 itself could not serve the
+// request, as opposed to rejecting something specific about it
+func isUpstreamFailure(class nobl9ErrorClass) bool {
+	switch class.Code {
+	case codeNobl9Unauthorized, codeNobl9RateLimited, codeNobl9Unavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// nobl9FieldErrors converts the error details reported by Nobl9 into field errors
+func nobl9FieldErrors(err error) []FieldError {
+	var httpErr *sdk.HTTPError
+	if !errors.As(err, &httpErr) {
+		return nil
+	}
+
+	fieldErrors := make([]FieldError, 0, len(httpErr.Errors))
+	for _, apiErr := range httpErr.Errors {
+		fieldError := FieldError{
+			Code:    apiErr.Code,
+			Message: apiErr.Title,
+		}
+		if fieldError.Code == "" {
+			fieldError.Code = codeNobl9ValidationFailed
+		}
+		if apiErr.Source != nil {
+			fieldError.Field = apiErr.Source.PropertyName
+			fieldError.Value = apiErr.Source.PropertyValue
+		}
+		fieldErrors = append(fieldErrors, fieldError)
+	}
+	return fieldErrors
+}
+
+// respondLambdaError sends an error response carrying a stable error code
+func respondLambdaError(statusCode int, code, message string, fieldErrors []FieldError) (events.APIGatewayProxyResponse, error) {
+	return respondLambdaJSON(statusCode, Response{
+		Success: false,
+		Code:    code,
+		Message: message,
+		Errors:  fieldErrors,
+	})
+}
+
+// respondNobl9Error sends an error response for a failed Nobl9 SDK call, prefixing the message
+// with a description of the failed operation
+func respondNobl9Error(err error, operation string) (events.APIGatewayProxyResponse, error) {
+	class := classifyNobl9Error(err)
+
+	var fieldErrors []FieldError
+	if class.Code == codeNobl9ValidationFailed {
+		fieldErrors = nobl9FieldErrors(err)
+	}
+
+	return respondLambdaError(class.StatusCode, class.Code, fmt.Sprintf("%s: %v", operation, err), fieldErrors)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nobl9/nobl9-go/sdk"
+	objectsV1 "github.com/nobl9/nobl9-go/sdk/endpoints/objects/v1"
+)
+
+func TestClassifyNobl9Error(t *testing.T) {
+	httpError := func(statusCode int) error {
+		return fmt.Errorf("apply failed: %w", &sdk.HTTPError{
+			StatusCode: statusCode,
+			APIErrors:  sdk.APIErrors{Errors: []sdk.APIError{{Title: "error"}}},
+		})
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedCode   string
+		expectedStatus int
+	}{
+		{"conflict", httpError(http.StatusConflict), codeConflict, http.StatusConflict},
+		{"bad request", httpError(http.StatusBadRequest), codeNobl9ValidationFailed, http.StatusUnprocessableEntity},
+		{"unprocessable", httpError(http.StatusUnprocessableEntity), codeNobl9ValidationFailed, http.StatusUnprocessableEntity},
+		{"unauthorized", httpError(http.StatusUnauthorized), codeNobl9Unauthorized, http.StatusBadGateway},
+		{"forbidden", httpError(http.StatusForbidden), codeNobl9Unauthorized, http.StatusBadGateway},
+		{"rate limited", httpError(http.StatusTooManyRequests), codeNobl9RateLimited, http.StatusTooManyRequests},
+		{"server error", httpError(http.StatusBadGateway), codeNobl9Unavailable, http.StatusServiceUnavailable},
+		{"not found", httpError(http.StatusNotFound), codeNobl9Error, http.StatusInternalServerError},
+		{"idp rejected", errors.New("error getting new access token from IDP: cannot access the token from POST https://idp/token, IDP replied with (status: 401): invalid_client"), codeNobl9Unauthorized, http.StatusBadGateway},
+		{"deadline", fmt.Errorf("failed to execute request: %w", context.DeadlineExceeded), codeNobl9Unavailable, http.StatusServiceUnavailable},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, codeNobl9Unavailable, http.StatusServiceUnavailable},
+		{"message mentioning conflict", errors.New("conflict while reading config: already exists"), codeNobl9Error, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		class := classifyNobl9Error(tt.err)
+		if class.Code != tt.expectedCode || class.StatusCode != tt.expectedStatus {
+			t.Errorf("classifyNobl9Error(%s) = %+v, want %s/%d", tt.name, class, tt.expectedCode, tt.expectedStatus)
+		}
+	}
+}
+
+// The SDK reports rejected credentials as a plain error carrying the IDP status in its message, which
+// idpStatusRegex must keep matching across SDK upgrades
+func TestClassifyNobl9ErrorFromIDP(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+	}))
+	defer idp.Close()
+
+	idpURL, _ := url.Parse(idp.URL)
+	client, err := newNobl9ClientFromConfig(Nobl9Config{
+		ClientID:       "client-id",
+		ClientSecret:   "client-secret",
+		OktaOrgURL:     idpURL,
+		OktaAuthServer: defaultNobl9OktaAuthServer,
+		URL:            idpURL,
+		Timeout:        5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("newNobl9ClientFromConfig() error = %v", err)
+	}
+
+	_, err = client.Objects().V1().GetV1alphaProjects(context.Background(), objectsV1.GetProjectsRequest{})
+	if err == nil {
+		t.Fatal("GetV1alphaProjects() with rejected credentials succeeded")
+	}
+	var httpErr *sdk.HTTPError
+	if errors.As(err, &httpErr) {
+		t.Skipf("the SDK now reports IDP failures as sdk.HTTPError, so idpStatusRegex can be removed: %v", err)
+	}
+	if class := classifyNobl9Error(err); class.Code != codeNobl9Unauthorized {
+		t.Errorf("classifyNobl9Error(%q) = %+v, want %s", err, class, codeNobl9Unauthorized)
+	}
+}
+
+func TestIsLookupUpstreamFailure(t *testing.T) {
+	if isLookupUpstreamFailure(&userNotFoundError{identifier: "user@example.com"}) {
+		t.Errorf("isLookupUpstreamFailure() = true for a missing user")
+	}
+
+	if !isLookupUpstreamFailure(fmt.Errorf("error retrieving user 'user@example.com': %w", &sdk.HTTPError{StatusCode: http.StatusServiceUnavailable})) {
+		t.Errorf("isLookupUpstreamFailure() = false for an unavailable Nobl9 API")
+	}
+
+	if isLookupUpstreamFailure(errors.New("unexpected number of users returned: 2")) {
+		t.Errorf("isLookupUpstreamFailure() = true for an unclassified error")
+	}
+}
+
+func TestRespondNobl9Error(t *testing.T) {
+	err := &sdk.HTTPError{
+		StatusCode: http.StatusBadRequest,
+		APIErrors: sdk.APIErrors{Errors: []sdk.APIError{{
+			Title:  "description is too long",
+			Source: &sdk.APIErrorSource{PropertyName: "$.spec.description", PropertyValue: "..."},
+		}}},
+	}
+
+	response, respErr := respondNobl9Error(err, "Failed to create project and assign roles")
+	if respErr != nil {
+		t.Errorf("respondNobl9Error() error = %v", respErr)
+	}
+
+	if response.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("respondNobl9Error() status = %d, want %d", response.StatusCode, http.StatusUnprocessableEntity)
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(response.Body), &resp); err != nil {
+		t.Errorf("Failed to parse response: %v", err)
+	}
+
+	if resp.Success || resp.Code != codeNobl9ValidationFailed {
+		t.Errorf("Response = %+v, want failure with code %s", resp, codeNobl9ValidationFailed)
+	}
+
+	if len(resp.Errors) != 1 || resp.Errors[0].Field != "$.spec.description" || resp.Errors[0].Message != "description is too long" {
+		t.Errorf("Response errors = %+v", resp.Errors)
+	}
+}
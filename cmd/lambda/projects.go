@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	v1alphaProject "github.com/nobl9/nobl9-go/manifest/v1alpha/project"
+	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
+	"github.com/nobl9/nobl9-go/sdk"
+	objectsV1 "github.com/nobl9/nobl9-go/sdk/endpoints/objects/v1"
+)
+
+// projectsPathPrefix is the path prefix for all project-scoped endpoints
+const projectsPathPrefix = "/api/projects/"
+
+// ProjectDetails describes an existing Nobl9 project and the roles granted on it
+type ProjectDetails struct {
+	Name         string               `json:"name"`                  // Name of the project
+	DisplayName  string               `json:"displayName,omitempty"` // Human-readable name of the project
+	Description  string               `json:"description"`           // Description of the project
+	Labels       map[string][]string  `json:"labels,omitempty"`      // Labels attached to the project
+	CreatedAt    string               `json:"createdAt,omitempty"`   // Creation time reported by Nobl9
+	CreatedBy    string               `json:"createdBy,omitempty"`   // Creator reported by Nobl9
+	RoleBindings []RoleBindingDetails `json:"roleBindings"`          // Role bindings scoped to the project
+}
+
+// RoleBindingDetails describes a single role binding on a project
+type RoleBindingDetails struct {
+	Name     string `json:"name"`               // Name of the role binding object
+	Role     string `json:"role"`               // Role granted by the binding
+	UserID   string `json:"userId,omitempty"`   // Nobl9 user ID, if the binding targets a user
+	Email    string `json:"email,omitempty"`    // User email, if it could be resolved
+	GroupRef string `json:"groupRef,omitempty"` // User group, if the binding targets a group
+}
+
+// GetProjectResponse defines the response for the project read endpoint
+type GetProjectResponse struct {
+	Response
+	Project *ProjectDetails `json:"project,omitempty"` // The project, present on success
+}
+
+// parseProjectPath splits a /api/projects/{name}[/...] path into the project name
+// and the remaining path segments. The path is already decoded by the adapter that received it.
+func parseProjectPath(path string) (string, []string, bool) {
+	if !strings.HasPrefix(path, projectsPathPrefix) {
+		return "", nil, false
+	}
+
+	trimmed := strings.Trim(strings.TrimPrefix(path, projectsPathPrefix), "/")
+	if trimmed == "" {
+		return "", nil, false
+	}
+
+	segments := strings.Split(trimmed, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return "", nil, false
+		}
+	}
+
+	return segments[0], segments[1:], true
+}
+
+// buildProjectDetails converts the project and its role bindings into the API representation.
+// Only role bindings whose ProjectRef matches the project are included; emails are taken from
+// the provided userID -> email map when available.
+func buildProjectDetails(project v1alphaProject.Project, roleBindings []v1alphaRoleBinding.RoleBinding, emails map[string]string) ProjectDetails {
+	details := ProjectDetails{
+		Name:         project.Metadata.Name,
+		DisplayName:  project.Metadata.DisplayName,
+		Description:  project.Spec.Description,
+		CreatedAt:    project.Spec.CreatedAt,
+		CreatedBy:    project.Spec.CreatedBy,
+		RoleBindings: []RoleBindingDetails{},
+	}
+
+	if len(project.Metadata.Labels) > 0 {
+		details.Labels = make(map[string][]string, len(project.Metadata.Labels))
+		for key, values := range project.Metadata.Labels {
+			details.Labels[key] = values
+		}
+	}
+
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Spec.ProjectRef != project.Metadata.Name {
+			continue
+		}
+
+		binding := RoleBindingDetails{
+			Name: roleBinding.Metadata.Name,
+			Role: roleBinding.Spec.RoleRef,
+		}
+		if roleBinding.Spec.User != nil {
+			binding.UserID = *roleBinding.Spec.User
+			binding.Email = emails[binding.UserID]
+		}
+		if roleBinding.Spec.GroupRef != nil {
+			binding.GroupRef = *roleBinding.Spec.GroupRef
+		}
+
+		details.RoleBindings = append(details.RoleBindings, binding)
+	}
+
+	return details
+}
+
+// getProjectRoleBindings fetches all role bindings whose ProjectRef matches the project
+func getProjectRoleBindings(ctx context.Context, client *sdk.Client, projectName string) ([]v1alphaRoleBinding.RoleBinding, error) {
+	roleBindings, err := client.Objects().V1().GetV1alphaRoleBindings(ctx, objectsV1.GetRoleBindingsRequest{
+		Project: projectName,
+	})
+	if err != nil {
+		invalidateIfUnauthorized(err)
+		return nil, err
+	}
+
+	matching := make([]v1alphaRoleBinding.RoleBinding, 0, len(roleBindings))
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Spec.ProjectRef == projectName {
+			matching = append(matching, roleBinding)
+		}
+	}
+	return matching, nil
+}
+
+// getProject fetches a single project by name, returning nil if it does not exist
+func getProject(ctx context.Context, client *sdk.Client, projectName string) (*v1alphaProject.Project, error) {
+	projects, err := client.Objects().V1().GetV1alphaProjects(ctx, objectsV1.GetProjectsRequest{
+		Names: []string{projectName},
+	})
+	if err != nil {
+		invalidateIfUnauthorized(err)
+		return nil, err
+	}
+
+	for _, project := range projects {
+		if project.Metadata.Name == projectName {
+			return &project, nil
+		}
+	}
+	return nil, nil
+}
+
+// resolveUserEmails looks up the email of every user referenced by the role bindings in parallel
+// through the shared user cache. Users that cannot be resolved map to an empty email.
+func resolveUserEmails(ctx context.Context, client *sdk.Client, roleBindings []v1alphaRoleBinding.RoleBinding) map[string]string {
+	var userIDs []string
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Spec.User != nil {
+			userIDs = append(userIDs, *roleBinding.Spec.User)
+		}
+	}
+	return lookupUserEmails(ctx, userLookups, client.Users().V2().GetUser, userIDs, userLookupConcurrencyFromEnv())
+}
+
+// handleGetProject returns a project and all role bindings assigned on it
+func handleGetProject(ctx context.Context, request events.APIGatewayProxyRequest, projectName string) (events.APIGatewayProxyResponse, error) {
+	// Only allow GET requests
+	if request.HTTPMethod != "GET" {
+		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
+	}
+
+	if err := validateProjectName(projectName); err != nil {
+		return respondLambdaWithStatus(http.StatusBadRequest, false, err.Error())
+	}
+
+	slog.InfoContext(ctx, "Processing get project request")
+
+	if err := authorize(ctx, authzRequest{Project: projectName}); err != nil {
+		return respondLambdaError(http.StatusForbidden, codeForbidden, err.Error(), nil)
+	}
+
+	// Create a context with timeout for all SDK operations
+	sdkCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	client, err := getNobl9Client(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to initialize Nobl9 client", "error", err)
+		return respondLambdaWithStatus(http.StatusInternalServerError, false, err.Error())
+	}
+
+	project, err := getProject(sdkCtx, client, projectName)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to retrieve project", "error", err)
+		return respondNobl9Error(err, fmt.Sprintf("Failed to retrieve project '%s'", projectName))
+	}
+	if project == nil {
+		return respondLambdaWithStatus(http.StatusNotFound, false, fmt.Sprintf("Project '%s' not found", projectName))
+	}
+
+	roleBindings, err := getProjectRoleBindings(sdkCtx, client, projectName)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to retrieve role bindings", "error", err)
+		return respondNobl9Error(err, fmt.Sprintf("Failed to retrieve role bindings for project '%s'", projectName))
+	}
+
+	emails := resolveUserEmails(sdkCtx, client, roleBindings)
+	details := buildProjectDetails(*project, roleBindings, emails)
+
+	slog.InfoContext(ctx, "Retrieved project", "roleBindings", len(details.RoleBindings))
+
+	return respondLambdaJSON(http.StatusOK, GetProjectResponse{
+		Response: Response{
+			Success: true,
+			Message: fmt.Sprintf("Project '%s' has %d role bindings", projectName, len(details.RoleBindings)),
+		},
+		Project: &details,
+	})
+}
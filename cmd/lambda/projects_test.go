@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	v1alphaProject "github.com/nobl9/nobl9-go/manifest/v1alpha/project"
+	v1alphaRoleBinding "github.com/nobl9/nobl9-go/manifest/v1alpha/rolebinding"
+)
+
+func TestParseProjectPath(t *testing.T) {
+	tests := []struct {
+		path         string
+		expectedName string
+		expectedRest []string
+		expectedOK   bool
+	}{
+		{"/api/projects/my-project", "my-project", []string{}, true},
+		{"/api/projects/my-project/", "my-project", []string{}, true},
+		{"/api/projects/my-project/members", "my-project", []string{"members"}, true},
+		{"/api/projects/my-project/members/user@example.com", "my-project", []string{"members", "user@example.com"}, true},
+		// Paths arrive decoded, so a literal percent sign is not decoded again
+		{"/api/projects/my-project/members/100%25", "my-project", []string{"members", "100%25"}, true},
+		{"/api/projects/", "", nil, false},
+		{"/api/projects", "", nil, false},
+		{"/api/create-project", "", nil, false},
+		{"/api/projects/my-project//members", "", nil, false},
+	}
+
+	for _, tt := range tests {
+		name, rest, ok := parseProjectPath(tt.path)
+		if ok != tt.expectedOK || name != tt.expectedName {
+			t.Errorf("parseProjectPath(%q) = %q, %v, want %q, %v", tt.path, name, ok, tt.expectedName, tt.expectedOK)
+		}
+		if ok && !reflect.DeepEqual(rest, tt.expectedRest) {
+			t.Errorf("parseProjectPath(%q) rest = %v, want %v", tt.path, rest, tt.expectedRest)
+		}
+	}
+}
+
+func TestBuildProjectDetails(t *testing.T) {
+	project := v1alphaProject.New(
+		v1alphaProject.Metadata{
+			Name:   "my-project",
+			Labels: map[string][]string{"team": {"payments"}},
+		},
+		v1alphaProject.Spec{Description: "Payments monitoring"},
+	)
+	roleBindings := []v1alphaRoleBinding.RoleBinding{
+		v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: "owner-binding"},
+			v1alphaRoleBinding.Spec{User: ptr("user-1"), RoleRef: "project-owner", ProjectRef: "my-project"},
+		),
+		v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: "group-binding"},
+			v1alphaRoleBinding.Spec{GroupRef: ptr("sre"), RoleRef: "project-viewer", ProjectRef: "my-project"},
+		),
+		v1alphaRoleBinding.New(
+			v1alphaRoleBinding.Metadata{Name: "other-project-binding"},
+			v1alphaRoleBinding.Spec{User: ptr("user-2"), RoleRef: "project-owner", ProjectRef: "other-project"},
+		),
+	}
+	emails := map[string]string{"user-1": "owner@example.com"}
+
+	details := buildProjectDetails(project, roleBindings, emails)
+
+	if details.Name != "my-project" || details.Description != "Payments monitoring" {
+		t.Errorf("buildProjectDetails() name/description = %q/%q", details.Name, details.Description)
+	}
+
+	if !reflect.DeepEqual(details.Labels, map[string][]string{"team": {"payments"}}) {
+		t.Errorf("buildProjectDetails() labels = %v", details.Labels)
+	}
+
+	expectedBindings := []RoleBindingDetails{
+		{Name: "owner-binding", Role: "project-owner", UserID: "user-1", Email: "owner@example.com"},
+		{Name: "group-binding", Role: "project-viewer", GroupRef: "sre"},
+	}
+	if !reflect.DeepEqual(details.RoleBindings, expectedBindings) {
+		t.Errorf("buildProjectDetails() role bindings = %+v, want %+v", details.RoleBindings, expectedBindings)
+	}
+}
+
+func TestHandleGetProjectValidation(t *testing.T) {
+	// Test invalid method
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/projects/valid-project",
+	}
+
+	response, err := handleGetProject(context.Background(), request, "valid-project")
+	if err != nil {
+		t.Errorf("handleGetProject() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("handleGetProject() status = %d, want %d", response.StatusCode, http.StatusMethodNotAllowed)
+	}
+
+	// Test invalid project name
+	request.HTTPMethod = "GET"
+	request.Path = "/api/projects/Invalid_Project"
+	response, err = handleGetProject(context.Background(), request, "Invalid_Project")
+	if err != nil {
+		t.Errorf("handleGetProject() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("handleGetProject() status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+
+	// Test valid input but missing credentials (should return 500)
+	request.Path = "/api/projects/valid-project"
+	response, err = handleRequest(context.Background(), request)
+	if err != nil {
+		t.Errorf("handleRequest() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("handleRequest() status = %d, want %d", response.StatusCode, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	defaultListenAddr   = ":8080"
+	maxRequestBodyBytes = 6 * 1024 * 1024 // Matches the Lambda request payload limit
+	shutdownTimeout     = 30 * time.Second
+)
+
+// proxyHandler is the signature shared by the Lambda handler and the routes it dispatches to
+type proxyHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// serverModeFromEnv reports whether NOBL9_WIZARD_SERVER selects the standalone HTTP server
+func serverModeFromEnv() bool {
+	return os.Getenv("NOBL9_WIZARD_SERVER") == "true"
+}
+
+// listenAddrFromEnv returns the server listen address from NOBL9_WIZARD_ADDR, defaulting to :8080
+func listenAddrFromEnv() string {
+	if addr := os.Getenv("NOBL9_WIZARD_ADDR"); addr != "" {
+		return addr
+	}
+	return defaultListenAddr
+}
+
+// proxyRequestFromHTTP converts an incoming HTTP request into the API Gateway request shape used by the handlers
+func proxyRequestFromHTTP(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBodyBytes))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:                      r.Method,
+		Path:                            r.URL.Path,
+		Headers:                         make(map[string]string, len(r.Header)),
+		MultiValueHeaders:               make(map[string][]string, len(r.Header)),
+		QueryStringParameters:           make(map[string]string),
+		MultiValueQueryStringParameters: make(map[string][]string),
+		Body:                            string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  requestIDFromHTTP(r),
+			HTTPMethod: r.Method,
+			Path:       r.URL.Path,
+		},
+	}
+
+	for name, values := range r.Header {
+		request.Headers[name] = strings.Join(values, ",")
+		request.MultiValueHeaders[name] = values
+	}
+	for name, values := range r.URL.Query() {
+		request.QueryStringParameters[name] = values[len(values)-1]
+		request.MultiValueQueryStringParameters[name] = values
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		request.RequestContext.Identity.SourceIP = host
+	}
+
+	return request, nil
+}
+
+// requestIDFromHTTP returns the X-Request-Id header set by a proxy in front of the server, or a new random ID
+func requestIDFromHTTP(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// writeProxyResponse writes an API Gateway response to an HTTP response writer
+func writeProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) {
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	w.WriteHeader(response.StatusCode)
+	io.WriteString(w, response.Body)
+}
+
+// newHTTPHandler adapts a Lambda handler to net/http so the same routes can be served without Lambda
+func newHTTPHandler(handler proxyHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, err := proxyRequestFromHTTP(r)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				response, _ := respondLambdaWithStatus(http.StatusRequestEntityTooLarge, false, "Request body too large")
+				writeProxyResponse(w, response)
+				return
+			}
+			response, _ := respondLambdaWithStatus(http.StatusBadRequest, false, "Failed to read request body")
+			writeProxyResponse(w, response)
+			return
+		}
+
+		response, err := handler(r.Context(), request)
+		if err != nil {
+			slog.ErrorContext(requestLogContext(r.Context(), request), "Handler failed", "error", err)
+			response, _ = respondLambdaWithStatus(http.StatusInternalServerError, false, "Internal server error")
+		}
+		writeProxyResponse(w, response)
+	})
+}
+
+// serve handles HTTP requests on the listener until ctx is done, then drains in-flight requests
+func serve(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.InfoContext(ctx, "Shutting down HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	slog.InfoContext(ctx, "HTTP server stopped")
+	return nil
+}
+
+// runServer listens on addr and serves the wizard API until ctx is done
+func runServer(ctx context.Context, addr string, handler proxyHandler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	slog.InfoContext(ctx, "Nobl9 Wizard HTTP server listening", "addr", listener.Addr().String())
+	return serve(ctx, listener, newHTTPHandler(handler))
+}
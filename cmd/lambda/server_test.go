@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestProxyRequestFromHTTP(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/create-project?dryRun=true&format=yaml&format=json", strings.NewReader(`{"appID":"my-project"}`))
+	r.Header.Set("Idempotency-Key", "key-1")
+	r.Header.Add("X-Forwarded-For", "10.0.0.1")
+	r.Header.Add("X-Forwarded-For", "10.0.0.2")
+
+	request, err := proxyRequestFromHTTP(r)
+	if err != nil {
+		t.Fatalf("proxyRequestFromHTTP() error = %v", err)
+	}
+
+	if request.HTTPMethod != "POST" || request.Path != "/api/create-project" || request.Body != `{"appID":"my-project"}` {
+		t.Errorf("proxyRequestFromHTTP() = %+v", request)
+	}
+	if request.QueryStringParameters["dryRun"] != "true" || request.QueryStringParameters["format"] != "json" ||
+		len(request.MultiValueQueryStringParameters["format"]) != 2 {
+		t.Errorf("proxyRequestFromHTTP() query = %v, %v", request.QueryStringParameters, request.MultiValueQueryStringParameters)
+	}
+	if getHeader(request, "idempotency-key") != "key-1" || request.Headers["X-Forwarded-For"] != "10.0.0.1,10.0.0.2" {
+		t.Errorf("proxyRequestFromHTTP() headers = %v", request.Headers)
+	}
+	if request.RequestContext.Identity.SourceIP != "192.0.2.1" {
+		t.Errorf("proxyRequestFromHTTP() source IP = %q", request.RequestContext.Identity.SourceIP)
+	}
+	if len(request.RequestContext.RequestID) != 32 {
+		t.Errorf("proxyRequestFromHTTP() request ID = %q, want a generated ID", request.RequestContext.RequestID)
+	}
+
+	// A request ID set by a proxy is kept
+	r = httptest.NewRequest("GET", "/health", nil)
+	r.Header.Set("X-Request-Id", "req-1")
+	if request, _ = proxyRequestFromHTTP(r); request.RequestContext.RequestID != "req-1" {
+		t.Errorf("proxyRequestFromHTTP() request ID = %q, want req-1", request.RequestContext.RequestID)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	server := httptest.NewServer(newHTTPHandler(handleRequest))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"health", "GET", "/health", "", http.StatusOK},
+		{"preflight", "OPTIONS", "/api/create-project", "", http.StatusOK},
+		{"validation", "POST", "/api/create-project", `{"appID":"","userGroups":[]}`, http.StatusBadRequest},
+		{"not found", "GET", "/unknown", "", http.StatusNotFound},
+		{"too large", "POST", "/api/create-project", strings.Repeat("x", maxRequestBodyBytes+1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			response, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("status = %d, want %d", response.StatusCode, tt.expectedStatus)
+			}
+			if response.Header.Get("Access-Control-Allow-Origin") != "*" {
+				t.Errorf("missing CORS headers: %v", response.Header)
+			}
+		})
+	}
+}
+
+func TestHTTPHandlerError(t *testing.T) {
+	handler := newHTTPHandler(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{}, errors.New("boom")
+	})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/health", nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusInternalServerError)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil || resp.Success {
+		t.Errorf("response = %s", recorder.Body.String())
+	}
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- serve(ctx, listener, handler) }()
+
+	// Start a request, then shut down while it is in flight
+	responseBody := make(chan string, 1)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responseBody <- err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		responseBody <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	if err := <-serveErr; err != nil {
+		t.Errorf("serve() error = %v", err)
+	}
+	if body := <-responseBody; body != "done" {
+		t.Errorf("in-flight request response = %q, want %q", body, "done")
+	}
+
+	// The listener is closed after shutdown
+	if _, err := http.Get("http://" + listener.Addr().String()); err == nil {
+		t.Errorf("server still accepting requests after shutdown")
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nobl9/nobl9-go/sdk"
+	usersV2 "github.com/nobl9/nobl9-go/sdk/endpoints/users/v2"
+)
+
+const (
+	defaultKnownUsersTTL   = time.Hour
+	knownUsersFetchTimeout = 30 * time.Second // Longest a fetch of the known users may take
+	maxUserSuggestions     = 3
+	maxSuggestionDistance  = 3
+	maxKnownUsers          = 10000 // Most users compared against an unknown email
+	usersAPIPath           = "usrmgmt/v2/users"
+)
+
+// knownUsers caches the organization's users for suggestions across warm invocations.
+// initialize replaces it with one using KNOWN_USERS_TTL.
+var knownUsers = newKnownUsersCache(defaultKnownUsersTTL)
+
+// knownUsersCache lazily fetches every user in the organization and reuses the list until it expires.
+// It is safe for concurrent use. The list is fetched outside the lock, once for all concurrent callers.
+type knownUsersCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	now       func() time.Time
+	users     []usersV2.User
+	expiresAt time.Time
+	fetching  *knownUsersFetch
+}
+
+// knownUsersFetch is a fetch of the known users shared by the callers waiting for it
+type knownUsersFetch struct {
+	done  chan struct{}
+	users []usersV2.User
+	err   error
+}
+
+// newKnownUsersCache creates an empty known users cache whose list expires after ttl
+func newKnownUsersCache(ttl time.Duration) *knownUsersCache {
+	return &knownUsersCache{
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// Get returns the cached users, fetching them if none are cached or the list has expired. The fetch
+// runs with its own timeout rather than the caller's deadline, so a caller that gives up waiting
+// does not cancel it for the others, and its result is still cached for later requests.
+func (c *knownUsersCache) Get(ctx context.Context, fetch func(ctx context.Context) ([]usersV2.User, error)) ([]usersV2.User, error) {
+	c.mu.Lock()
+	if c.users != nil && c.now().Before(c.expiresAt) {
+		users := c.users
+		c.mu.Unlock()
+		return users, nil
+	}
+	current := c.fetching
+	if current == nil {
+		current = &knownUsersFetch{done: make(chan struct{})}
+		c.fetching = current
+		go c.fetch(context.WithoutCancel(ctx), current, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.users, current.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch runs a shared fetch of the known users and caches its result if it succeeds
+func (c *knownUsersCache) fetch(ctx context.Context, current *knownUsersFetch, fetch func(ctx context.Context) ([]usersV2.User, error)) {
+	ctx, cancel := context.WithTimeout(ctx, knownUsersFetchTimeout)
+	defer cancel()
+
+	slog.InfoContext(ctx, "Fetching known Nobl9 users for suggestions")
+	current.users, current.err = fetch(ctx)
+	if current.err != nil {
+		invalidateIfUnauthorized(current.err)
+	}
+
+	c.mu.Lock()
+	if current.err == nil {
+		c.users = current.users
+		c.expiresAt = c.now().Add(c.ttl)
+	}
+	c.fetching = nil
+	c.mu.Unlock()
+	close(current.done)
+}
+
+// fetchAllUsers lists the users in the organization from the Users API. The SDK only exposes
+// single-user lookups, so the search endpoint is called directly. The endpoint is not paginated:
+// it returns every user whose name or email contains the phrase, which for an empty phrase is
+// every user. Large organizations are capped to maxKnownUsers, so suggestions stay cheap to compute
+// at the cost of missing some candidates.
+func fetchAllUsers(client *sdk.Client) func(ctx context.Context) ([]usersV2.User, error) {
+	return func(ctx context.Context) ([]usersV2.User, error) {
+		req, err := client.CreateRequest(ctx, http.MethodGet, usersAPIPath, nil, url.Values{"phrase": []string{""}}, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		return decodeKnownUsers(ctx, resp.Body)
+	}
+}
+
+// decodeKnownUsers reads a Users API response, keeping at most maxKnownUsers users.
+// The users are streamed, so the rest of a larger response is never read or held in memory.
+func decodeKnownUsers(ctx context.Context, r io.Reader) ([]usersV2.User, error) {
+	users, err := streamKnownUsers(ctx, json.NewDecoder(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode users: %w", err)
+	}
+	return users, nil
+}
+
+// streamKnownUsers decodes the users array of a Users API response one user at a time, skipping other fields
+func streamKnownUsers(ctx context.Context, dec *json.Decoder) ([]usersV2.User, error) {
+	users := []usersV2.User{}
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object, got %v", token)
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key != "users" {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		token, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if token == nil {
+			continue
+		}
+		if token != json.Delim('[') {
+			return nil, fmt.Errorf("expected a users array, got %v", token)
+		}
+		for dec.More() {
+			if len(users) == maxKnownUsers {
+				slog.WarnContext(ctx, "Too many Nobl9 users for suggestions, ignoring the rest", "kept", maxKnownUsers)
+				return users, nil
+			}
+			var user usersV2.User
+			if err := dec.Decode(&user); err != nil {
+				return nil, err
+			}
+			users = append(users, user)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+// editDistance returns the optimal string alignment distance between two strings: the number of
+// insertions, deletions, substitutions and transpositions of adjacent characters needed to turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous2 := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	return previous[len(rb)]
+}
+
+// splitEmail splits a lowercase email into its local part and domain
+func splitEmail(email string) (string, string) {
+	local, domain, _ := strings.Cut(strings.ToLower(email), "@")
+	return local, domain
+}
+
+// suggestUsers proposes the emails of known users that an unresolved email was probably meant to be.
+// Candidates are users with the same local part at another domain, such as a mistyped or personal domain,
+// and users whose email is within a few typos of it. The closest candidates come first.
+func suggestUsers(identifier string, known []usersV2.User) []string {
+	email := strings.ToLower(strings.TrimSpace(identifier))
+	local, _ := splitEmail(email)
+
+	// Allow fewer typos in short emails, so they are not matched to unrelated users
+	threshold := min(maxSuggestionDistance, max(1, len(email)/6))
+
+	type candidate struct {
+		email    string
+		distance int
+	}
+	var candidates []candidate
+	for _, user := range known {
+		candidateEmail := strings.ToLower(user.Email)
+		if candidateEmail == "" || candidateEmail == email {
+			continue
+		}
+
+		distance := editDistance(email, candidateEmail)
+		if candidateLocal, _ := splitEmail(candidateEmail); candidateLocal == local {
+			// Domain correction: the same person at a known domain
+			distance = min(distance, 1)
+		}
+		if distance <= threshold {
+			candidates = append(candidates, candidate{email: user.Email, distance: distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].email < candidates[j].email
+	})
+
+	var suggestions []string
+	for _, c := range candidates {
+		if len(suggestions) == maxUserSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.email)
+	}
+	return suggestions
+}
+
+// suggestionsFor proposes known users for an unresolved email. Suggestions are best effort:
+// if the users cannot be fetched, none are returned.
+func suggestionsFor(ctx context.Context, client *sdk.Client, identifier string) []string {
+	if !strings.Contains(identifier, "@") {
+		return nil
+	}
+
+	known, err := knownUsers.Get(ctx, fetchAllUsers(client))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to fetch known users for suggestions", "error", err)
+		return nil
+	}
+
+	suggestions := suggestUsers(identifier, known)
+	if len(suggestions) > 0 {
+		slog.InfoContext(ctx, "Suggesting users for unresolved user", "user", identifier, "suggestions", suggestions)
+	}
+	return suggestions
+}
+
+// addUserSuggestions attaches suggestions to every lookup that failed because the user was not found
+func addUserSuggestions(ctx context.Context, client *sdk.Client, lookups map[string]userLookupResult) {
+	for identifier, lookup := range lookups {
+		var notFound *userNotFoundError
+		if errors.As(lookup.Err, &notFound) {
+			notFound.suggestions = suggestionsFor(ctx, client, identifier)
+		}
+	}
+}
+
+// userNotFoundFieldError describes a user that could not be found, with any suggestions, at the given field
+func userNotFoundFieldError(field string, err *userNotFoundError) FieldError {
+	return FieldError{
+		Field:       field,
+		Code:        codeUserNotFound,
+		Message:     err.Error(),
+		Value:       err.identifier,
+		Suggestions: err.suggestions,
+	}
+}
+
+// knownUsersTTLFromEnv reads how long the known users list is cached from KNOWN_USERS_TTL
+func knownUsersTTLFromEnv() time.Duration {
+	value := os.Getenv("KNOWN_USERS_TTL")
+	if value == "" {
+		return defaultKnownUsersTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		slog.Warn("Invalid KNOWN_USERS_TTL, using default", "value", value, "default", defaultKnownUsersTTL.String())
+		return defaultKnownUsersTTL
+	}
+	return ttl
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	usersV2 "github.com/nobl9/nobl9-go/sdk/endpoints/users/v2"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"example", "example", 0},
+		{"exmaple", "example", 1}, // Transposition
+		{"exampl", "example", 1},  // Deletion
+		{"examplee", "example", 1},
+		{"exbmple", "example", 1},
+		{"kitten", "sitting", 3},
+		{"zoë", "zoe", 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestSuggestUsers(t *testing.T) {
+	known := []usersV2.User{
+		{UserID: "00u1", Email: "jsmith@example.com"},
+		{UserID: "00u2", Email: "jsmyth@example.com"},
+		{UserID: "00u3", Email: "alice.jones@example.com"},
+		{UserID: "00u4", Email: "bob@example.com"},
+		{UserID: "00u5", Email: "Alice.Jones@subsidiary.com"},
+		{UserID: "00u6", Email: ""},
+	}
+
+	tests := []struct {
+		name       string
+		identifier string
+		expected   []string
+	}{
+		{"domain typo", "jsmith@exmaple.com", []string{"jsmith@example.com", "jsmyth@example.com"}},
+		{"wrong domain", "bob@gmail.com", []string{"bob@example.com"}},
+		{"local typo, closest first", "jsmiht@example.com", []string{"jsmith@example.com", "jsmyth@example.com"}},
+		{"same person at several domains", "alice.jones@exampel.com", []string{"Alice.Jones@subsidiary.com", "alice.jones@example.com"}},
+		{"case-insensitive", "BOB@EXMAPLE.COM", []string{"bob@example.com"}},
+		{"unrelated", "carol@example.com", nil},
+		{"short email", "bo@example.com", []string{"bob@example.com"}},
+	}
+
+	for _, tt := range tests {
+		got := suggestUsers(tt.identifier, known)
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("suggestUsers(%s: %q) = %v, want %v", tt.name, tt.identifier, got, tt.expected)
+		}
+	}
+
+	// At most a few suggestions are returned
+	var many []usersV2.User
+	for _, email := range []string{"ann@example.com", "anne@example.com", "anna@example.com", "anni@example.com", "ana@example.com"} {
+		many = append(many, usersV2.User{Email: email})
+	}
+	if got := suggestUsers("anm@example.com", many); len(got) != maxUserSuggestions {
+		t.Errorf("suggestUsers() = %v, want %d suggestions", got, maxUserSuggestions)
+	}
+}
+
+func TestUserNotFoundErrorSuggestions(t *testing.T) {
+	err := &userNotFoundError{identifier: "jsmith@exmaple.com"}
+	if err.Error() != "User with email 'jsmith@exmaple.com' not found in Nobl9" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+
+	err.suggestions = []string{"jsmith@example.com", "jsmyth@example.com"}
+	expected := "User with email 'jsmith@exmaple.com' not found in Nobl9. Did you mean jsmith@example.com or jsmyth@example.com?"
+	if err.Error() != expected {
+		t.Errorf("Error() = %q, want %q", err.Error(), expected)
+	}
+
+	fieldError := userNotFoundFieldError("userGroups[0].userIds[1]", err)
+	if fieldError.Code != codeUserNotFound || fieldError.Value != "jsmith@exmaple.com" || len(fieldError.Suggestions) != 2 {
+		t.Errorf("userNotFoundFieldError() = %+v", fieldError)
+	}
+}
+
+func TestKnownUsersCache(t *testing.T) {
+	calls := 0
+	fail := false
+	fetch := func(ctx context.Context) ([]usersV2.User, error) {
+		calls++
+		if fail {
+			return nil, errors.New("service unavailable")
+		}
+		return []usersV2.User{{UserID: "00u1", Email: "jsmith@example.com"}}, nil
+	}
+
+	cache := newKnownUsersCache(time.Hour)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if users, err := cache.Get(context.Background(), fetch); err != nil || len(users) != 1 {
+			t.Errorf("Get() = %v, %v", users, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Get() within TTL fetched %d times, want 1", calls)
+	}
+
+	// Expired lists are fetched again, and failures are not cached
+	now = now.Add(2 * time.Hour)
+	fail = true
+	if _, err := cache.Get(context.Background(), fetch); err == nil {
+		t.Errorf("Get() should return the fetch error")
+	}
+	fail = false
+	if users, err := cache.Get(context.Background(), fetch); err != nil || len(users) != 1 || calls != 3 {
+		t.Errorf("Get() after recovery = %v, %v (calls = %d)", users, err, calls)
+	}
+}
+
+func TestKnownUsersCacheSharesFetch(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fetch := func(ctx context.Context) ([]usersV2.User, error) {
+		calls.Add(1)
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("fetch has no deadline of its own")
+		}
+		<-release
+		return []usersV2.User{{UserID: "00u1", Email: "jsmith@example.com"}}, nil
+	}
+	cache := newKnownUsersCache(time.Hour)
+
+	// A caller that gives up waiting returns without cancelling the fetch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.Get(ctx, fetch); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	// Concurrent callers wait for the same fetch, without holding the lock
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if users, err := cache.Get(context.Background(), fetch); err != nil || len(users) != 1 {
+				t.Errorf("Get() = %v, %v", users, err)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("concurrent Get() fetched %d times, want 1", calls.Load())
+	}
+}
+
+func TestDecodeKnownUsers(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+
+	users, err := decodeKnownUsers(context.Background(), strings.NewReader(`{"users":[{"userId":"00u1","email":"alice@example.com"}]}`))
+	if err != nil || len(users) != 1 || users[0].Email != "alice@example.com" {
+		t.Errorf("decodeKnownUsers() = %+v, %v", users, err)
+	}
+
+	// An organization without users is cached as an empty list rather than refetched
+	if users, err := decodeKnownUsers(context.Background(), strings.NewReader(`{}`)); err != nil || users == nil {
+		t.Errorf("decodeKnownUsers() without users = %#v, %v", users, err)
+	}
+
+	// Large organizations are capped
+	var body strings.Builder
+	body.WriteString(`{"users":[`)
+	for i := 0; i <= maxKnownUsers; i++ {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		fmt.Fprintf(&body, `{"userId":"00u%d","email":"user%d@example.com"}`, i, i)
+	}
+	body.WriteString(`]}`)
+	if users, err := decodeKnownUsers(context.Background(), strings.NewReader(body.String())); err != nil || len(users) != maxKnownUsers {
+		t.Errorf("decodeKnownUsers() kept %d users, %v, want %d", len(users), err, maxKnownUsers)
+	}
+
+	// The response is not read past the cap
+	capped := body.String()[:strings.Index(body.String(), fmt.Sprintf(`{"userId":"00u%d"`, maxKnownUsers))]
+	truncated := io.MultiReader(strings.NewReader(capped), iotest.ErrReader(errors.New("read past the cap")))
+	if users, err := decodeKnownUsers(context.Background(), truncated); err != nil || len(users) != maxKnownUsers {
+		t.Errorf("decodeKnownUsers() kept %d users, %v, want %d without reading further", len(users), err, maxKnownUsers)
+	}
+
+	// Other fields are skipped and a null list is empty
+	if users, err := decodeKnownUsers(context.Background(), strings.NewReader(`{"total":1,"meta":{"a":[1]},"users":null}`)); err != nil || users == nil || len(users) != 0 {
+		t.Errorf("decodeKnownUsers() with other fields = %#v, %v", users, err)
+	}
+
+	if _, err := decodeKnownUsers(context.Background(), strings.NewReader(`not json`)); err == nil {
+		t.Error("decodeKnownUsers() with an invalid body succeeded")
+	}
+}
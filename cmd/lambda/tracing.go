@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName         = "nobl9-wizard"
+	defaultServiceName = "nobl9-wizard"
+)
+
+// tracer returns the tracer of the wizard's spans from the global tracer provider, which records
+// nothing unless setupTracing installed an exporting provider. It is looked up on every use so
+// replacing the provider takes effect immediately.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// tracerProvider is the exporting tracer provider, or nil if tracing is disabled
+var tracerProvider *sdktrace.TracerProvider
+
+// tracingEnabledFromEnv reports whether an OTLP endpoint is configured with the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables
+func tracingEnabledFromEnv() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// setupTracing installs the W3C trace context propagator and, if an OTLP endpoint is configured,
+// a tracer provider exporting spans to it over HTTP. The exporter reads the other standard
+// OTEL_EXPORTER_OTLP_* variables, such as headers and timeouts, itself.
+func setupTracing(ctx context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !tracingEnabledFromEnv() {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", defaultServiceName), attribute.String("service.version", appVersion)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	installTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithBatcher(urlQueryRedactingExporter{exporter}), sdktrace.WithResource(res)))
+	slog.InfoContext(ctx, "Exporting traces over OTLP")
+	return nil
+}
+
+// urlQueryRedactingExporter strips the query string from the URLs otelhttp records on client spans
+// before exporting them. User lookups search Nobl9 with ?phrase=<email>, and traces are not redacted
+// like logs. otelhttp sets the URL after the span starts, so it cannot be rewritten in a span processor.
+type urlQueryRedactingExporter struct {
+	sdktrace.SpanExporter
+}
+
+// ExportSpans exports the spans with their URL attributes redacted
+func (e urlQueryRedactingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	redacted := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, span := range spans {
+		redacted[i] = redactSpanURLs(span)
+	}
+	return e.SpanExporter.ExportSpans(ctx, redacted)
+}
+
+// urlRedactedSpan is a span whose attributes were replaced
+type urlRedactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+}
+
+// Attributes returns the redacted attributes
+func (s urlRedactedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+// redactSpanURLs returns the span with the query string and fragment removed from its URL attributes
+func redactSpanURLs(span sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	attrs := span.Attributes()
+	var redacted []attribute.KeyValue
+	for i, attr := range attrs {
+		if attr.Key != "url.full" && attr.Key != "http.url" {
+			continue
+		}
+		u, err := url.Parse(attr.Value.AsString())
+		if err != nil || (u.RawQuery == "" && u.Fragment == "") {
+			continue
+		}
+		if redacted == nil {
+			redacted = append([]attribute.KeyValue(nil), attrs...)
+		}
+		u.RawQuery, u.Fragment = "", ""
+		redacted[i] = attribute.String(string(attr.Key), u.String())
+	}
+	if redacted == nil {
+		return span
+	}
+	return urlRedactedSpan{ReadOnlySpan: span, attributes: redacted}
+}
+
+// installTracerProvider makes provider the global tracer provider
+func installTracerProvider(provider *sdktrace.TracerProvider) {
+	tracerProvider = provider
+	otel.SetTracerProvider(provider)
+}
+
+// flushTraces exports buffered spans. Lambda freezes the container between invocations,
+// so spans are flushed at the end of every invocation rather than left to the batcher.
+func flushTraces(ctx context.Context) {
+	if tracerProvider == nil {
+		return
+	}
+	if err := tracerProvider.ForceFlush(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to export traces", "error", err)
+	}
+}
+
+// shutdownTracing flushes remaining spans and stops the exporter
+func shutdownTracing(ctx context.Context) {
+	if tracerProvider == nil {
+		return
+	}
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to shut down tracing", "error", err)
+	}
+}
+
+// startSpan starts an internal span as a child of the span in ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends a span, marking it failed if err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startRequestSpan continues the trace of the incoming traceparent header, if any, and starts the server span of a request.
+// Only the route template is recorded, since paths such as /api/projects/{name}/members/{user} hold emails.
+func startRequestSpan(ctx context.Context, request events.APIGatewayProxyRequest) (context.Context, trace.Span) {
+	headers := make(http.Header, len(request.Headers))
+	for name, value := range request.Headers {
+		headers.Set(name, value)
+	}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(headers))
+
+	route := metricsRoute(request.Path)
+	return tracer().Start(ctx, request.HTTPMethod+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", request.HTTPMethod),
+			attribute.String("http.route", route),
+			attribute.String("aws.request_id", request.RequestContext.RequestID),
+		),
+	)
+}
+
+// endRequestSpan records the response status of a request and ends its span. Server errors mark the span failed.
+func endRequestSpan(span trace.Span, response events.APIGatewayProxyResponse, err error) {
+	span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+	if err == nil && response.StatusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+	}
+	endSpan(span, err)
+}
+
+// traceLogAttrs returns the trace and span IDs of the span in ctx, so logs can be matched to traces
+func traceLogAttrs(ctx context.Context) []slog.Attr {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	return []slog.Attr{
+		slog.String("traceId", spanContext.TraceID().String()),
+		slog.String("spanId", spanContext.SpanID().String()),
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nobl9/nobl9-go/manifest"
+	"github.com/nobl9/nobl9-go/sdk"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// captureSpans exports spans to memory for the rest of the test
+func captureSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	if err := setupTracing(context.Background()); err != nil {
+		t.Fatalf("setupTracing() error = %v", err)
+	}
+	installTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(urlQueryRedactingExporter{exporter})))
+	t.Cleanup(func() {
+		shutdownTracing(context.Background())
+		tracerProvider = nil
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+	return exporter
+}
+
+// findSpan returns the exported span with the name
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q in %v", name, exporter.GetSpans().Snapshots())
+	return tracetest.SpanStub{}
+}
+
+// assertNoEmailsInSpans fails the test if any exported span attribute or event attribute holds an email
+func assertNoEmailsInSpans(t *testing.T, exporter *tracetest.InMemoryExporter) {
+	t.Helper()
+	for _, stub := range exporter.GetSpans() {
+		attrs := stub.Attributes
+		for _, event := range stub.Events {
+			attrs = append(attrs, event.Attributes...)
+		}
+		for _, attr := range attrs {
+			if strings.Contains(attr.Value.Emit(), "@") {
+				t.Errorf("span %q attribute %s records an email: %s", stub.Name, attr.Key, attr.Value.Emit())
+			}
+		}
+	}
+}
+
+// spanAttribute returns the value of a span attribute
+func spanAttribute(span tracetest.SpanStub, key string) (attribute.Value, bool) {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracingEnabledFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if tracingEnabledFromEnv() {
+		t.Error("tracingEnabledFromEnv() = true without an endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://localhost:4318/v1/traces")
+	if !tracingEnabledFromEnv() {
+		t.Error("tracingEnabledFromEnv() = false with a traces endpoint")
+	}
+}
+
+func TestHandleRequestTracing(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	exporter := captureSpans(t)
+	buf := captureLogs(t, slog.LevelInfo)
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/api/unknown",
+		Headers:    map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID: "req-42",
+		},
+	}
+	response, err := handleRequest(context.Background(), request)
+	if err != nil || response.StatusCode != http.StatusNotFound {
+		t.Fatalf("handleRequest() = %d, %v", response.StatusCode, err)
+	}
+
+	// The request span continues the caller's trace
+	span := findSpan(t, exporter, "GET other")
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the incoming trace", span.SpanContext.TraceID())
+	}
+	if span.Parent.SpanID().String() != "00f067aa0ba902b7" || !span.Parent.IsRemote() {
+		t.Errorf("parent = %v, want the incoming span", span.Parent)
+	}
+	if status, _ := spanAttribute(span, "http.response.status_code"); status.AsInt64() != http.StatusNotFound {
+		t.Errorf("http.response.status_code = %v, want %d", status.AsInt64(), http.StatusNotFound)
+	}
+	if span.Status.Code == codes.Error {
+		t.Errorf("client error marked the span failed: %v", span.Status)
+	}
+
+	// Log lines carry the trace and span IDs
+	for _, line := range decodeLogLines(t, buf) {
+		if line["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" || line["spanId"] != span.SpanContext.SpanID().String() {
+			t.Errorf("log line %v is missing the trace", line)
+		}
+	}
+}
+
+func TestApplyObjectsTracing(t *testing.T) {
+	exporter := captureSpans(t)
+	captureMetrics(t)
+
+	applyErr := &sdk.HTTPError{StatusCode: http.StatusBadGateway}
+	apply := func(ctx context.Context, objects []manifest.Object) error { return applyErr }
+	if err := applyObjects(context.Background(), "add_members", nil, apply); !errors.Is(err, applyErr) {
+		t.Fatalf("applyObjects() error = %v, want the apply error", err)
+	}
+
+	span := findSpan(t, exporter, "Nobl9 add_members")
+	if span.Status.Code != codes.Error {
+		t.Errorf("status = %v, want an error", span.Status)
+	}
+	if operation, _ := spanAttribute(span, "nobl9.operation"); operation.AsString() != "add_members" {
+		t.Errorf("nobl9.operation = %q", operation.AsString())
+	}
+}
+
+func TestLookupUserTracing(t *testing.T) {
+	exporter := captureSpans(t)
+	captureMetrics(t)
+	users := &fakeUsers{users: map[string]string{"alice@example.com": "user-alice"}}
+	cache := newUserLookupCache(defaultUserLookupCacheTTL)
+
+	tests := []struct {
+		identifier string
+		found      bool
+		failed     bool
+	}{
+		{"alice@example.com", true, false},
+		{"bob@example.com", false, false},
+		{"error@example.com", false, true},
+	}
+	for _, tt := range tests {
+		exporter.Reset()
+		lookupUser(context.Background(), cache, users.GetUser, tt.identifier)
+
+		span := findSpan(t, exporter, "Nobl9 GetUser")
+		if failed := span.Status.Code == codes.Error; failed != tt.failed {
+			t.Errorf("%s: span failed = %v, want %v", tt.identifier, failed, tt.failed)
+		}
+		if found, ok := spanAttribute(span, "nobl9.user.found"); !tt.failed && (!ok || found.AsBool() != tt.found) {
+			t.Errorf("%s: nobl9.user.found = %v, want %v", tt.identifier, found.AsBool(), tt.found)
+		}
+
+		// Traces are not redacted, so emails must never be recorded
+		assertNoEmailsInSpans(t, exporter)
+	}
+
+	// Cached users are not looked up again
+	exporter.Reset()
+	lookupUser(context.Background(), cache, users.GetUser, "alice@example.com")
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("cached lookup exported %d spans", len(spans))
+	}
+}
+
+func TestTracingOmitsEmailsFromURLs(t *testing.T) {
+	exporter := captureSpans(t)
+	captureMetrics(t)
+	captureLogs(t, slog.LevelInfo)
+
+	// Member paths hold the member's email; the request span only records the route
+	response, err := handleRequest(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "DELETE",
+		Path:       "/api/projects/Invalid_Name/members/alice@example.com",
+	})
+	if err != nil || response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("handleRequest() = %d, %v", response.StatusCode, err)
+	}
+	span := findSpan(t, exporter, "DELETE /api/projects/{name}/members/{user}")
+	if route, _ := spanAttribute(span, "http.route"); route.AsString() != "/api/projects/{name}/members/{user}" {
+		t.Errorf("http.route = %q", route.AsString())
+	}
+
+	// Nobl9 user lookups search with the email in the query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	resp, err := client.Get(server.URL + "/api/usrmgmt/v2/users?phrase=" + url.QueryEscape("alice@example.com"))
+	if err != nil {
+		t.Fatalf("GET users error = %v", err)
+	}
+	resp.Body.Close()
+	clientSpan := findSpan(t, exporter, "HTTP GET")
+	if full, _ := spanAttribute(clientSpan, "url.full"); full.AsString() != server.URL+"/api/usrmgmt/v2/users" {
+		t.Errorf("url.full = %q, want the URL without its query", full.AsString())
+	}
+
+	assertNoEmailsInSpans(t, exporter)
+}
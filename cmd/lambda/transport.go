@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// nobl9DomainSuffix matches the hosts of the Nobl9 SaaS API and its Okta organization
+const nobl9DomainSuffix = ".nobl9.com"
+
+// ssmGetParameterAPI is the subset of the SSM client used to load a CA bundle
+type ssmGetParameterAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// Nobl9TransportConfig configures the HTTP transport used for requests to Nobl9
+type Nobl9TransportConfig struct {
+	CABundleFile       string   // PEM file with additional CAs to trust, e.g. a TLS-intercepting proxy
+	CABundleParamName  string   // Parameter Store name holding a PEM CA bundle, used instead of CABundleFile
+	ClientCertFile     string   // PEM client certificate presented for mTLS
+	ClientKeyFile      string   // PEM private key of the client certificate
+	ProxyURL           *url.URL // Explicit proxy; the standard proxy environment variables are used if unset
+	InsecureSkipVerify bool     // Disables certificate verification; prefer a CA bundle
+	Hosts              []string // Hosts routed through the transport in addition to *.nobl9.com
+}
+
+// nobl9TransportConfigFromEnv reads the Nobl9 transport settings from the environment
+func nobl9TransportConfigFromEnv(nobl9Config Nobl9Config) (Nobl9TransportConfig, error) {
+	config := Nobl9TransportConfig{
+		CABundleFile:       os.Getenv("NOBL9_CA_BUNDLE_FILE"),
+		CABundleParamName:  os.Getenv("NOBL9_CA_BUNDLE_PARAM_NAME"),
+		ClientCertFile:     os.Getenv("NOBL9_CLIENT_CERT_FILE"),
+		ClientKeyFile:      os.Getenv("NOBL9_CLIENT_KEY_FILE"),
+		InsecureSkipVerify: os.Getenv("NOBL9_SKIP_TLS_VERIFY") == "true",
+	}
+
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return Nobl9TransportConfig{}, fmt.Errorf("NOBL9_CLIENT_CERT_FILE and NOBL9_CLIENT_KEY_FILE must be set together")
+	}
+
+	if proxyURL := os.Getenv("NOBL9_PROXY_URL"); proxyURL != "" {
+		parsed, err := parseNobl9URL("NOBL9_PROXY_URL", proxyURL)
+		if err != nil {
+			return Nobl9TransportConfig{}, err
+		}
+		config.ProxyURL = parsed
+	}
+
+	// Self-hosted Nobl9 and custom Okta organizations are not under nobl9.com
+	for _, u := range []*url.URL{nobl9Config.URL, nobl9Config.OktaOrgURL} {
+		if u != nil {
+			config.Hosts = append(config.Hosts, u.Hostname())
+		}
+	}
+
+	return config, nil
+}
+
+// loadCABundle returns the system certificate pool extended with the configured CA bundle,
+// or nil if no bundle is configured
+func loadCABundle(ctx context.Context, config Nobl9TransportConfig, ssmAPI ssmGetParameterAPI) (*x509.CertPool, error) {
+	var pemData []byte
+	var source string
+
+	switch {
+	case config.CABundleParamName != "":
+		source = config.CABundleParamName
+		output, err := ssmAPI.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(config.CABundleParamName),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CA bundle parameter: %w", err)
+		}
+		pemData = []byte(aws.ToString(output.Parameter.Value))
+	case config.CABundleFile != "":
+		source = config.CABundleFile
+		data, err := os.ReadFile(config.CABundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pemData = data
+	default:
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		slog.WarnContext(ctx, "System certificate pool unavailable, trusting only the CA bundle", "error", err)
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", source)
+	}
+
+	slog.InfoContext(ctx, "Trusting additional CAs for Nobl9 requests", "source", source)
+	return pool, nil
+}
+
+// newNobl9Transport builds the HTTP transport used for requests to Nobl9
+func newNobl9Transport(ctx context.Context, config Nobl9TransportConfig, ssmAPI ssmGetParameterAPI) (*http.Transport, error) {
+	rootCAs, err := loadCABundle(ctx, config, ssmAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		slog.InfoContext(ctx, "Presenting client certificate to Nobl9", "file", config.ClientCertFile)
+	}
+
+	if config.InsecureSkipVerify {
+		slog.WarnContext(ctx, "SSL certificate verification is DISABLED for Nobl9 requests (NOBL9_SKIP_TLS_VERIFY=true)")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.ProxyURL != nil {
+		slog.InfoContext(ctx, "Sending Nobl9 requests through proxy", "proxy", config.ProxyURL.Redacted())
+		proxy = http.ProxyURL(config.ProxyURL)
+	}
+
+	return &http.Transport{
+		TLSClientConfig:       tlsConfig,
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}, nil
+}
+
+// hostRoutingTransport sends requests for Nobl9 hosts through a dedicated transport
+// and everything else through the fallback transport
+type hostRoutingTransport struct {
+	hosts    map[string]bool   // Exact hosts routed to nobl9
+	nobl9    http.RoundTripper // Transport for Nobl9 hosts
+	fallback http.RoundTripper // Transport for all other hosts
+}
+
+// newHostRoutingTransport creates a transport routing *.nobl9.com and the given hosts to nobl9
+func newHostRoutingTransport(hosts []string, nobl9, fallback http.RoundTripper) *hostRoutingTransport {
+	t := &hostRoutingTransport{
+		hosts:    make(map[string]bool, len(hosts)),
+		nobl9:    nobl9,
+		fallback: fallback,
+	}
+	for _, host := range hosts {
+		t.hosts[strings.ToLower(host)] = true
+	}
+	return t
+}
+
+// isNobl9Host reports whether requests to host go through the Nobl9 transport
+func (t *hostRoutingTransport) isNobl9Host(host string) bool {
+	host = strings.ToLower(host)
+	return t.hosts[host] || host == strings.TrimPrefix(nobl9DomainSuffix, ".") || strings.HasSuffix(host, nobl9DomainSuffix)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *hostRoutingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.isNobl9Host(req.URL.Hostname()) {
+		return t.nobl9.RoundTrip(req)
+	}
+	return t.fallback.RoundTrip(req)
+}
+
+// installNobl9Transport builds the Nobl9 transport once and routes Nobl9 traffic through it.
+// The Nobl9 SDK sends API, token and JWKS requests through internal clients that always use
+// http.DefaultTransport, so it is wrapped rather than replaced; other traffic is unaffected.
+func installNobl9Transport(ctx context.Context, ssmAPI ssmGetParameterAPI) error {
+	nobl9Config, err := nobl9ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	config, err := nobl9TransportConfigFromEnv(nobl9Config)
+	if err != nil {
+		return err
+	}
+
+	transport, err := newNobl9Transport(ctx, config, ssmAPI)
+	if err != nil {
+		return err
+	}
+
+	// Nobl9 requests get client spans and carry the trace context
+	http.DefaultTransport = newHostRoutingTransport(config.Hosts, otelhttp.NewTransport(transport), http.DefaultTransport)
+	return nil
+}
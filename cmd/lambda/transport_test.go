@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// fakeSSM returns fixed parameter values by name
+type fakeSSM map[string]string
+
+func (f fakeSSM) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	value, ok := f[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Value: aws.String(value)}}, nil
+}
+
+// writePEM writes a PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// serverCAPEM returns the PEM certificate of a TLS test server
+func serverCAPEM(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func TestHostRoutingTransport(t *testing.T) {
+	var used string
+	transport := newHostRoutingTransport([]string{"nobl9.internal.example.com"},
+		roundTripFunc(func(req *http.Request) (*http.Response, error) { used = "nobl9"; return nil, nil }),
+		roundTripFunc(func(req *http.Request) (*http.Response, error) { used = "fallback"; return nil, nil }),
+	)
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://app.nobl9.com/api/apply", "nobl9"},
+		{"https://accounts.nobl9.com/oauth2/token", "nobl9"},
+		{"https://nobl9.com/", "nobl9"},
+		{"https://NOBL9.Internal.Example.com:8443/api", "nobl9"},
+		{"https://example.com/", "fallback"},
+		{"https://notnobl9.com/", "fallback"},
+		{"https://ssm.us-east-1.amazonaws.com/", "fallback"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		transport.RoundTrip(req)
+		if used != tt.expected {
+			t.Errorf("RoundTrip(%s) used %s transport, want %s", tt.url, used, tt.expected)
+		}
+	}
+}
+
+func TestNobl9TransportConfigFromEnv(t *testing.T) {
+	t.Setenv("NOBL9_CA_BUNDLE_FILE", "/etc/ssl/proxy-ca.pem")
+	t.Setenv("NOBL9_CA_BUNDLE_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_CERT_FILE", "")
+	t.Setenv("NOBL9_CLIENT_KEY_FILE", "")
+	t.Setenv("NOBL9_SKIP_TLS_VERIFY", "")
+	t.Setenv("NOBL9_PROXY_URL", "http://proxy.example.com:3128")
+
+	apiURL, _ := url.Parse("https://nobl9.internal.example.com/api")
+	oktaURL, _ := url.Parse("https://accounts.nobl9.com")
+	config, err := nobl9TransportConfigFromEnv(Nobl9Config{URL: apiURL, OktaOrgURL: oktaURL})
+	if err != nil {
+		t.Fatalf("nobl9TransportConfigFromEnv() error = %v", err)
+	}
+	if config.CABundleFile != "/etc/ssl/proxy-ca.pem" || config.ProxyURL.String() != "http://proxy.example.com:3128" ||
+		config.InsecureSkipVerify || len(config.Hosts) != 2 || config.Hosts[0] != "nobl9.internal.example.com" {
+		t.Errorf("nobl9TransportConfigFromEnv() = %+v", config)
+	}
+
+	// A client certificate needs its key
+	t.Setenv("NOBL9_CLIENT_CERT_FILE", "/etc/ssl/client.pem")
+	if _, err := nobl9TransportConfigFromEnv(Nobl9Config{}); err == nil {
+		t.Errorf("nobl9TransportConfigFromEnv() with certificate but no key should fail")
+	}
+	t.Setenv("NOBL9_CLIENT_CERT_FILE", "")
+
+	t.Setenv("NOBL9_PROXY_URL", "proxy:3128")
+	if _, err := nobl9TransportConfigFromEnv(Nobl9Config{}); err == nil {
+		t.Errorf("nobl9TransportConfigFromEnv() with relative proxy URL should fail")
+	}
+}
+
+func TestNewNobl9TransportCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	get := func(config Nobl9TransportConfig, ssmAPI ssmGetParameterAPI) error {
+		transport, err := newNobl9Transport(context.Background(), config, ssmAPI)
+		if err != nil {
+			return err
+		}
+		response, err := (&http.Client{Transport: transport, Timeout: 5 * time.Second}).Get(server.URL)
+		if err == nil {
+			response.Body.Close()
+		}
+		return err
+	}
+
+	// The test server's certificate is not trusted by default
+	if err := get(Nobl9TransportConfig{}, nil); err == nil {
+		t.Errorf("request without CA bundle should fail verification")
+	}
+
+	if err := get(Nobl9TransportConfig{CABundleFile: caFile}, nil); err != nil {
+		t.Errorf("request with CA bundle file error = %v", err)
+	}
+
+	ssmAPI := fakeSSM{"/nobl9-wizard/ca-bundle": serverCAPEM(server)}
+	if err := get(Nobl9TransportConfig{CABundleParamName: "/nobl9-wizard/ca-bundle"}, ssmAPI); err != nil {
+		t.Errorf("request with CA bundle parameter error = %v", err)
+	}
+
+	if err := get(Nobl9TransportConfig{CABundleParamName: "/nobl9-wizard/missing"}, ssmAPI); err == nil {
+		t.Errorf("missing CA bundle parameter should fail")
+	}
+
+	emptyFile := filepath.Join(dir, "empty.pem")
+	os.WriteFile(emptyFile, []byte("not a certificate"), 0o600)
+	if err := get(Nobl9TransportConfig{CABundleFile: emptyFile}, nil); err == nil {
+		t.Errorf("CA bundle without certificates should fail")
+	}
+}
+
+func TestNewNobl9TransportClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "nobl9-wizard" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// Generate a self-signed client certificate
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nobl9-wizard"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	dir := t.TempDir()
+	config := Nobl9TransportConfig{
+		CABundleFile:   writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw),
+		ClientCertFile: writePEM(t, dir, "client.pem", "CERTIFICATE", certDER),
+		ClientKeyFile:  writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER),
+	}
+
+	transport, err := newNobl9Transport(context.Background(), config, nil)
+	if err != nil {
+		t.Fatalf("newNobl9Transport() error = %v", err)
+	}
+	response, err := (&http.Client{Transport: transport, Timeout: 5 * time.Second}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with client certificate error = %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("request with client certificate status = %d, want %d", response.StatusCode, http.StatusOK)
+	}
+
+	// Unreadable key files are reported
+	config.ClientKeyFile = filepath.Join(dir, "missing.pem")
+	if _, err := newNobl9Transport(context.Background(), config, nil); err == nil {
+		t.Errorf("newNobl9Transport() with missing key should fail, got %v", err)
+	}
+}
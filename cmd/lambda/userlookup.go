@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nobl9/nobl9-go/sdk"
+	usersV2 "github.com/nobl9/nobl9-go/sdk/endpoints/users/v2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultUserLookupConcurrency = 8
+	defaultUserLookupCacheTTL    = 15 * time.Minute
+)
+
+// userLookups caches resolved users across warm invocations.
+// initialize replaces it with one using USER_LOOKUP_CACHE_TTL.
+var userLookups = newUserLookupCache(defaultUserLookupCacheTTL)
+
+// userGetter fetches a Nobl9 user by email or user ID, returning nil if there is no such user
+type userGetter func(ctx context.Context, identifier string) (*usersV2.User, error)
+
+// userLookupResult is the outcome of resolving one user identifier
+type userLookupResult struct {
+	UserID string
+	Err    error
+}
+
+// userCacheEntry is a resolved user and when it must be looked up again
+type userCacheEntry struct {
+	user      usersV2.User
+	expiresAt time.Time
+}
+
+// userLookupCache remembers users found by email or user ID for a limited time. Users that were not found
+// are not cached, so they resolve as soon as they are invited. It is safe for concurrent use.
+type userLookupCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]userCacheEntry // Keyed by userCacheKey
+}
+
+// newUserLookupCache creates an empty user cache whose entries expire after ttl
+func newUserLookupCache(ttl time.Duration) *userLookupCache {
+	return &userLookupCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]userCacheEntry),
+	}
+}
+
+// userCacheKey normalizes an identifier for the cache: emails are case-insensitive, user IDs are not
+func userCacheKey(identifier string) string {
+	if strings.Contains(identifier, "@") {
+		return strings.ToLower(identifier)
+	}
+	return identifier
+}
+
+// Get returns the cached user for an email or user ID, if it has not expired
+func (c *userLookupCache) Get(identifier string) (*usersV2.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := userCacheKey(identifier)
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	user := entry.user
+	return &user, true
+}
+
+// Put caches the user found for an email or user ID
+func (c *userLookupCache) Put(identifier string, user usersV2.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[userCacheKey(identifier)] = userCacheEntry{user: user, expiresAt: c.now().Add(c.ttl)}
+}
+
+// lookupUser fetches the Nobl9 user for an email or user ID with getUser unless cached
+func lookupUser(ctx context.Context, cache *userLookupCache, getUser userGetter, userIdentifier string) (*usersV2.User, error) {
+	if user, ok := cache.Get(userIdentifier); ok {
+		slog.DebugContext(ctx, "Found cached user", "user", userIdentifier, "userId", user.UserID)
+		recordUserLookup(true, 0, false, false)
+		return user, nil
+	}
+
+	// Identifiers are not recorded on the span, since traces are not redacted like logs
+	ctx, span := startSpan(ctx, "Nobl9 GetUser", attribute.Bool("nobl9.user.is_email", strings.Contains(userIdentifier, "@")))
+
+	slog.DebugContext(ctx, "Looking up user", "user", userIdentifier)
+	start := time.Now()
+	user, err := getUser(ctx, userIdentifier)
+	if err != nil {
+		recordUserLookup(false, time.Since(start), false, true)
+		invalidateIfUnauthorized(err)
+		endSpan(span, err)
+		return nil, fmt.Errorf("error retrieving user '%s': %w", userIdentifier, err)
+	}
+	// The Users API searches by phrase and returns a single partial match as the user, so the
+	// email or user ID must match exactly: bob@acme.com must not resolve to bob@acme.com.au
+	found := user != nil && userMatchesIdentifier(*user, userIdentifier)
+	span.SetAttributes(attribute.Bool("nobl9.user.found", found))
+	endSpan(span, nil)
+	if !found {
+		recordUserLookup(false, time.Since(start), true, false)
+		return nil, &userNotFoundError{identifier: userIdentifier}
+	}
+	recordUserLookup(false, time.Since(start), false, false)
+	slog.DebugContext(ctx, "Found user", "user", userIdentifier, "userId", user.UserID)
+	cache.Put(userIdentifier, *user)
+	return user, nil
+}
+
+// userMatchesIdentifier reports whether the user is the one named by an email, compared case-insensitively, or a user ID
+func userMatchesIdentifier(user usersV2.User, userIdentifier string) bool {
+	if strings.Contains(userIdentifier, "@") {
+		return strings.EqualFold(user.Email, userIdentifier)
+	}
+	return user.UserID == userIdentifier
+}
+
+// lookupUserID returns the Nobl9 user ID for an identifier, looking emails up with getUser unless cached.
+// Identifiers that are not emails are assumed to already be user IDs.
+func lookupUserID(ctx context.Context, cache *userLookupCache, getUser userGetter, userIdentifier string) (string, error) {
+	if !strings.Contains(userIdentifier, "@") {
+		// This is a user ID
+		slog.DebugContext(ctx, "Using provided user ID", "userId", userIdentifier)
+		return userIdentifier, nil
+	}
+
+	// This is an email, try to get the user by email
+	user, err := lookupUser(ctx, cache, getUser, userIdentifier)
+	if err != nil {
+		return "", err
+	}
+	return user.UserID, nil
+}
+
+// forEachUnique calls fn once for every distinct identifier with at most concurrency calls in flight
+func forEachUnique(identifiers []string, concurrency int, fn func(identifier string)) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	seen := make(map[string]bool, len(identifiers))
+
+	for _, identifier := range identifiers {
+		if seen[identifier] {
+			continue
+		}
+		seen[identifier] = true
+
+		// Wait for a free slot before starting the goroutine, so at most concurrency goroutines exist at once
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(identifier string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			fn(identifier)
+		}(identifier)
+	}
+
+	wg.Wait()
+}
+
+// lookupUserIDs resolves every identifier with at most concurrency lookups in flight.
+// Each identifier is looked up once, however often it is listed.
+func lookupUserIDs(ctx context.Context, cache *userLookupCache, getUser userGetter, identifiers []string, concurrency int) map[string]userLookupResult {
+	results := make(map[string]userLookupResult, len(identifiers))
+	var mu sync.Mutex
+
+	forEachUnique(identifiers, concurrency, func(identifier string) {
+		userID, err := lookupUserID(ctx, cache, getUser, identifier)
+
+		mu.Lock()
+		defer mu.Unlock()
+		results[identifier] = userLookupResult{UserID: userID, Err: err}
+	})
+	return results
+}
+
+// lookupUserEmails resolves the email of every user ID with at most concurrency lookups in flight.
+// Users that cannot be resolved map to an empty email.
+func lookupUserEmails(ctx context.Context, cache *userLookupCache, getUser userGetter, userIDs []string, concurrency int) map[string]string {
+	emails := make(map[string]string, len(userIDs))
+	var mu sync.Mutex
+
+	forEachUnique(userIDs, concurrency, func(userID string) {
+		email := ""
+		if user, err := lookupUser(ctx, cache, getUser, userID); err != nil {
+			slog.WarnContext(ctx, "Failed to resolve email for user", "userId", userID, "error", err)
+		} else {
+			email = user.Email
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		emails[userID] = email
+	})
+	return emails
+}
+
+// resolveUserIDs looks up every user listed in the groups in parallel through the shared user cache,
+// suggesting known users for emails that were not found
+func resolveUserIDs(ctx context.Context, client *sdk.Client, userGroups []UserGroup) map[string]userLookupResult {
+	var identifiers []string
+	for _, group := range userGroups {
+		identifiers = append(identifiers, splitUserIdentifiers(group.UserIDs)...)
+	}
+	lookups := lookupUserIDs(ctx, userLookups, client.Users().V2().GetUser, identifiers, userLookupConcurrencyFromEnv())
+	addUserSuggestions(ctx, client, lookups)
+	return lookups
+}
+
+// assignUsers pairs every user listed in the groups with the user ID it was resolved to. Users that were
+// not found are reported as messages and field errors instead; a failed lookup is returned as the error.
+func assignUsers(ctx context.Context, userGroups []UserGroup, lookups map[string]userLookupResult) ([]userAssignment, []string, []FieldError, error) {
+	var assignments []userAssignment
+	var messages []string
+	var fieldErrors []FieldError
+	for groupIndex, group := range userGroups {
+		for _, entry := range userIdentifierEntries(group.UserIDs) {
+			field := userIdentifierField(groupIndex, entry.Position)
+			lookup := lookups[entry.Identifier]
+			if lookup.Err != nil {
+				if isLookupUpstreamFailure(lookup.Err) {
+					return nil, nil, nil, lookup.Err
+				}
+				slog.InfoContext(ctx, "User not found", "user", entry.Identifier, "field", field)
+				messages = append(messages, capitalizeMessage(lookup.Err.Error()))
+				var notFound *userNotFoundError
+				if errors.As(lookup.Err, &notFound) {
+					fieldErrors = append(fieldErrors, userNotFoundFieldError(field, notFound))
+				}
+				continue
+			}
+
+			assignments = append(assignments, userAssignment{Identifier: entry.Identifier, UserID: lookup.UserID, Role: group.Role, Field: field})
+		}
+	}
+	return assignments, messages, fieldErrors, nil
+}
+
+// userLookupConcurrencyFromEnv reads how many users are looked up in parallel from USER_LOOKUP_CONCURRENCY
+func userLookupConcurrencyFromEnv() int {
+	value := os.Getenv("USER_LOOKUP_CONCURRENCY")
+	if value == "" {
+		return defaultUserLookupConcurrency
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		slog.Warn("Invalid USER_LOOKUP_CONCURRENCY, using default", "value", value, "default", defaultUserLookupConcurrency)
+		return defaultUserLookupConcurrency
+	}
+	return concurrency
+}
+
+// userLookupCacheTTLFromEnv reads how long resolved users are cached from USER_LOOKUP_CACHE_TTL
+func userLookupCacheTTLFromEnv() time.Duration {
+	value := os.Getenv("USER_LOOKUP_CACHE_TTL")
+	if value == "" {
+		return defaultUserLookupCacheTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		slog.Warn("Invalid USER_LOOKUP_CACHE_TTL, using default", "value", value, "default", defaultUserLookupCacheTTL.String())
+		return defaultUserLookupCacheTTL
+	}
+	return ttl
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nobl9/nobl9-go/sdk"
+	usersV2 "github.com/nobl9/nobl9-go/sdk/endpoints/users/v2"
+)
+
+// fakeUsers is a userGetter backed by a map of email to user ID that counts its calls
+type fakeUsers struct {
+	mu       sync.Mutex
+	users    map[string]string
+	calls    map[string]int
+	inFlight int
+	maxLoad  int
+}
+
+func (f *fakeUsers) GetUser(ctx context.Context, identifier string) (*usersV2.User, error) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[identifier]++
+	f.inFlight++
+	if f.inFlight > f.maxLoad {
+		f.maxLoad = f.inFlight
+	}
+	f.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inFlight--
+	if strings.HasPrefix(identifier, "error@") {
+		return nil, &sdk.HTTPError{StatusCode: http.StatusServiceUnavailable}
+	}
+	userID, ok := f.users[identifier]
+	if !ok {
+		return nil, nil
+	}
+	return &usersV2.User{UserID: userID, Email: identifier}, nil
+}
+
+func TestLookupUserIDs(t *testing.T) {
+	users := &fakeUsers{users: map[string]string{
+		"alice@example.com": "00u1",
+		"bob@example.com":   "00u2",
+		"carol@example.com": "00u3",
+	}}
+	identifiers := []string{
+		"alice@example.com", "bob@example.com", "alice@example.com", "carol@example.com",
+		"missing@example.com", "error@example.com", "00u9abcdef", "bob@example.com",
+	}
+
+	results := lookupUserIDs(context.Background(), newUserLookupCache(time.Minute), users.GetUser, identifiers, 2)
+
+	if len(results) != 6 {
+		t.Errorf("lookupUserIDs() returned %d results, want 6", len(results))
+	}
+	if results["alice@example.com"].UserID != "00u1" || results["00u9abcdef"].UserID != "00u9abcdef" {
+		t.Errorf("lookupUserIDs() = %+v", results)
+	}
+	var notFound *userNotFoundError
+	if !errors.As(results["missing@example.com"].Err, &notFound) {
+		t.Errorf("lookupUserIDs() missing user error = %v, want userNotFoundError", results["missing@example.com"].Err)
+	}
+	if err := results["error@example.com"].Err; err == nil || !isLookupUpstreamFailure(err) {
+		t.Errorf("lookupUserIDs() upstream error = %v, want upstream failure", err)
+	}
+
+	// Each email is fetched once and user IDs are never fetched
+	for identifier, calls := range users.calls {
+		if calls != 1 {
+			t.Errorf("GetUser(%s) called %d times, want 1", identifier, calls)
+		}
+	}
+	if users.calls["00u9abcdef"] != 0 {
+		t.Errorf("GetUser() called for a user ID")
+	}
+	if users.maxLoad > 2 {
+		t.Errorf("lookupUserIDs() ran %d lookups at once, want at most 2", users.maxLoad)
+	}
+}
+
+func TestForEachUniqueBoundsGoroutines(t *testing.T) {
+	const concurrency = 2
+	identifiers := make([]string, 50)
+	for i := range identifiers {
+		identifiers[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+
+	baseline := runtime.NumGoroutine()
+	started := make(chan struct{}, len(identifiers))
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		forEachUnique(identifiers, concurrency, func(identifier string) {
+			started <- struct{}{}
+			<-release
+		})
+	}()
+
+	// With every slot taken, the remaining identifiers wait without a goroutine of their own
+	for i := 0; i < concurrency; i++ {
+		<-started
+	}
+	if extra := runtime.NumGoroutine() - baseline; extra > concurrency+1 {
+		t.Errorf("forEachUnique() runs %d goroutines, want at most %d", extra, concurrency+1)
+	}
+	close(release)
+	<-done
+}
+
+func TestAssignUsersFieldPaths(t *testing.T) {
+	captureLogs(t, slog.LevelInfo)
+	users := &fakeUsers{users: map[string]string{"alice@example.com": "00u1"}}
+	groups := []UserGroup{
+		{UserIDs: "alice@example.com, ,missing@example.com", Role: "project-viewer"},
+		{UserIDs: ",x", Role: "project-editor"},
+	}
+	lookups := lookupUserIDs(context.Background(), newUserLookupCache(time.Minute), users.GetUser, []string{"alice@example.com", "missing@example.com", "x"}, 2)
+
+	assignments, messages, fieldErrors, err := assignUsers(context.Background(), groups, lookups)
+	if err != nil {
+		t.Fatalf("assignUsers() error = %v", err)
+	}
+	if len(messages) != 1 || len(fieldErrors) != 1 || fieldErrors[0].Field != "userGroups[0].userIds[2]" {
+		t.Errorf("assignUsers() not found = %v, %+v, want userGroups[0].userIds[2]", messages, fieldErrors)
+	}
+
+	// Positions count empty entries, the same way validation reports them
+	if validation := validateUserGroups(groups); len(validation) != 1 || validation[0].Field != "userGroups[1].userIds[1]" {
+		t.Errorf("validateUserGroups() = %+v, want userGroups[1].userIds[1]", validation)
+	}
+	if len(assignments) != 2 || assignments[0].Field != "userGroups[0].userIds[0]" || assignments[1].Field != "userGroups[1].userIds[1]" {
+		t.Errorf("assignUsers() assignments = %+v", assignments)
+	}
+
+	// Wrapped not-found errors are still reported against their field
+	lookups["missing@example.com"] = userLookupResult{Err: fmt.Errorf("lookup failed: %w", &userNotFoundError{identifier: "missing@example.com"})}
+	if _, _, fieldErrors, _ := assignUsers(context.Background(), groups, lookups); len(fieldErrors) != 1 || fieldErrors[0].Value != "missing@example.com" {
+		t.Errorf("assignUsers() with a wrapped error = %+v", fieldErrors)
+	}
+
+	// A failed lookup stops the assignment
+	lookups["alice@example.com"] = userLookupResult{Err: &sdk.HTTPError{StatusCode: http.StatusServiceUnavailable}}
+	if _, _, _, err := assignUsers(context.Background(), groups, lookups); !isLookupUpstreamFailure(err) {
+		t.Errorf("assignUsers() error = %v, want the upstream failure", err)
+	}
+}
+
+func TestLookupUserEmails(t *testing.T) {
+	captureMetrics(t)
+	captureLogs(t, slog.LevelInfo)
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	getUser := func(ctx context.Context, identifier string) (*usersV2.User, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[identifier]++
+		switch identifier {
+		case "00u1":
+			return &usersV2.User{UserID: "00u1", Email: "alice@example.com"}, nil
+		case "00u2":
+			return &usersV2.User{UserID: "00u2", Email: "bob@example.com"}, nil
+		case "00u9":
+			return nil, &sdk.HTTPError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil, nil
+	}
+	cache := newUserLookupCache(time.Minute)
+	userIDs := []string{"00u1", "00u2", "00u1", "00u3", "00u9"}
+
+	emails := lookupUserEmails(context.Background(), cache, getUser, userIDs, 2)
+	expected := map[string]string{"00u1": "alice@example.com", "00u2": "bob@example.com", "00u3": "", "00u9": ""}
+	if !reflect.DeepEqual(emails, expected) {
+		t.Errorf("lookupUserEmails() = %v, want %v", emails, expected)
+	}
+
+	// Resolved users come from the cache the second time
+	lookupUserEmails(context.Background(), cache, getUser, userIDs, 2)
+	if calls["00u1"] != 1 || calls["00u2"] != 1 || calls["00u3"] != 2 {
+		t.Errorf("GetUser() calls = %v, want resolved users fetched once", calls)
+	}
+}
+
+func TestUserLookupCache(t *testing.T) {
+	users := &fakeUsers{users: map[string]string{"alice@example.com": "00u1"}}
+	cache := newUserLookupCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for _, identifier := range []string{"alice@example.com", "Alice@Example.com"} {
+		if userID, err := lookupUserID(context.Background(), cache, users.GetUser, identifier); err != nil || userID != "00u1" {
+			t.Errorf("lookupUserID(%s) = %q, %v", identifier, userID, err)
+		}
+	}
+	if users.calls["alice@example.com"] != 1 || users.calls["Alice@Example.com"] != 0 {
+		t.Errorf("cached lookups called GetUser %v", users.calls)
+	}
+
+	// Expired entries are fetched again
+	now = now.Add(2 * time.Minute)
+	lookupUserID(context.Background(), cache, users.GetUser, "alice@example.com")
+	if users.calls["alice@example.com"] != 2 {
+		t.Errorf("lookup after TTL did not refetch (calls = %d)", users.calls["alice@example.com"])
+	}
+
+	// Users that were not found are not cached
+	lookupUserID(context.Background(), cache, users.GetUser, "bob@example.com")
+	users.users["bob@example.com"] = "00u2"
+	if userID, err := lookupUserID(context.Background(), cache, users.GetUser, "bob@example.com"); err != nil || userID != "00u2" {
+		t.Errorf("lookupUserID() after invite = %q, %v", userID, err)
+	}
+}
+
+func TestLookupUserRequiresExactMatch(t *testing.T) {
+	// The Users API returns a lone partial match for a phrase as if it were the user
+	nearMiss := func(ctx context.Context, identifier string) (*usersV2.User, error) {
+		return &usersV2.User{UserID: "00u7", Email: "bob@acme.com.au"}, nil
+	}
+
+	tests := []struct {
+		identifier string
+		found      bool
+	}{
+		{"bob@acme.com", false},
+		{"BOB@acme.com.AU", true},
+		{"00u7", true},
+		{"00u", false},
+	}
+	for _, tt := range tests {
+		user, err := lookupUser(context.Background(), newUserLookupCache(time.Minute), nearMiss, tt.identifier)
+		if tt.found {
+			if err != nil || user == nil || user.UserID != "00u7" {
+				t.Errorf("lookupUser(%s) = %v, %v, want the user", tt.identifier, user, err)
+			}
+			continue
+		}
+		var notFound *userNotFoundError
+		if !errors.As(err, &notFound) {
+			t.Errorf("lookupUser(%s) error = %v, want userNotFoundError", tt.identifier, err)
+		}
+	}
+}
+
+func TestUserLookupSettingsFromEnv(t *testing.T) {
+	t.Setenv("USER_LOOKUP_CONCURRENCY", "")
+	t.Setenv("USER_LOOKUP_CACHE_TTL", "")
+	if userLookupConcurrencyFromEnv() != defaultUserLookupConcurrency || userLookupCacheTTLFromEnv() != defaultUserLookupCacheTTL {
+		t.Errorf("defaults not applied")
+	}
+
+	t.Setenv("USER_LOOKUP_CONCURRENCY", "16")
+	t.Setenv("USER_LOOKUP_CACHE_TTL", "5m")
+	if userLookupConcurrencyFromEnv() != 16 || userLookupCacheTTLFromEnv() != 5*time.Minute {
+		t.Errorf("userLookupConcurrencyFromEnv() = %d, userLookupCacheTTLFromEnv() = %s", userLookupConcurrencyFromEnv(), userLookupCacheTTLFromEnv())
+	}
+
+	buf := captureLogs(t, slog.LevelInfo)
+	t.Setenv("USER_LOOKUP_CONCURRENCY", "-1")
+	t.Setenv("USER_LOOKUP_CACHE_TTL", "soon")
+	if userLookupConcurrencyFromEnv() != defaultUserLookupConcurrency || userLookupCacheTTLFromEnv() != defaultUserLookupCacheTTL {
+		t.Errorf("invalid values not replaced by defaults")
+	}
+
+	// Invalid values are reported through the structured logger
+	lines := decodeLogLines(t, buf)
+	if len(lines) != 2 || lines[0]["level"] != "WARN" || lines[0]["value"] != "-1" || lines[1]["value"] != "soon" {
+		t.Errorf("log lines = %v, want a warning for each invalid value", lines)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	usersV2 "github.com/nobl9/nobl9-go/sdk/endpoints/users/v2"
+)
+
+const (
+	userLookupPath     = "/api/users/lookup"
+	maxUserLookupBatch = 100
+)
+
+// UserLookupRequest is the body of a batch user lookup
+type UserLookupRequest struct {
+	Identifiers []string `json:"identifiers"` // Emails or Nobl9 user IDs to look up
+}
+
+// UserLookupResult reports whether a single identifier resolves to a Nobl9 user
+type UserLookupResult struct {
+	Identifier  string   `json:"identifier"`            // Email or user ID as requested
+	Resolved    bool     `json:"resolved"`              // Whether a Nobl9 user was found
+	UserID      string   `json:"userId,omitempty"`      // Nobl9 user ID, if resolved
+	DisplayName string   `json:"displayName,omitempty"` // User's full name, if resolved
+	Email       string   `json:"email,omitempty"`       // User's email, if resolved
+	Code        string   `json:"code,omitempty"`        // Why the identifier did not resolve
+	Message     string   `json:"message,omitempty"`     // Human-readable reason the identifier did not resolve
+	Suggestions []string `json:"suggestions,omitempty"` // Known users an unresolved email may have been meant to be
+}
+
+// UserLookupResponse lists the lookup result of every requested identifier
+type UserLookupResponse struct {
+	Response
+	Results []UserLookupResult `json:"results"`
+}
+
+// displayName builds a user's full name, falling back to their email
+func displayName(user *usersV2.User) string {
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	return user.Email
+}
+
+// lookupUsers resolves every identifier through the same cached lookup as project creation.
+// It returns the first upstream failure, if any, since results would then be incomplete.
+func lookupUsers(ctx context.Context, cache *userLookupCache, getUser userGetter, identifiers []string, concurrency int) ([]UserLookupResult, error) {
+	byIdentifier := make(map[string]UserLookupResult, len(identifiers))
+	upstreamErrors := make(map[string]error)
+	var mu sync.Mutex
+
+	forEachUnique(identifiers, concurrency, func(identifier string) {
+		result := UserLookupResult{Identifier: identifier}
+		var upstreamErr error
+
+		if err := validateUserIdentifier(identifier); err != nil {
+			result.Code = validationErrorCode(err)
+			result.Message = err.Error()
+		} else if user, err := lookupUser(ctx, cache, getUser, identifier); err != nil {
+			if isLookupUpstreamFailure(err) {
+				upstreamErr = err
+			}
+			result.Code = codeUserNotFound
+			result.Message = capitalizeMessage(err.Error())
+		} else {
+			result.Resolved = true
+			result.UserID = user.UserID
+			result.DisplayName = displayName(user)
+			result.Email = user.Email
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		byIdentifier[identifier] = result
+		if upstreamErr != nil {
+			upstreamErrors[identifier] = upstreamErr
+		}
+	})
+
+	// Report results, and the first upstream failure, in request order
+	var results []UserLookupResult
+	seen := make(map[string]bool, len(byIdentifier))
+	for _, identifier := range identifiers {
+		if seen[identifier] {
+			continue
+		}
+		seen[identifier] = true
+		if err, ok := upstreamErrors[identifier]; ok {
+			return nil, err
+		}
+		results = append(results, byIdentifier[identifier])
+	}
+	return results, nil
+}
+
+// userLookupIdentifiersFromRequest reads the identifiers to look up from the email query parameter
+// of a GET request or the body of a POST request
+func userLookupIdentifiersFromRequest(request events.APIGatewayProxyRequest) ([]string, error) {
+	var identifiers []string
+	switch request.HTTPMethod {
+	case "GET":
+		values := request.MultiValueQueryStringParameters["email"]
+		if len(values) == 0 && request.QueryStringParameters["email"] != "" {
+			values = []string{request.QueryStringParameters["email"]}
+		}
+		for _, value := range values {
+			identifiers = append(identifiers, splitUserIdentifiers(value)...)
+		}
+		if len(identifiers) == 0 {
+			return nil, errors.New("the 'email' query parameter is required")
+		}
+	case "POST":
+		var req UserLookupRequest
+		if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+			return nil, fmt.Errorf("invalid request body: %v", err)
+		}
+		for _, identifier := range req.Identifiers {
+			if identifier = strings.TrimSpace(identifier); identifier != "" {
+				identifiers = append(identifiers, identifier)
+			}
+		}
+		if len(identifiers) == 0 {
+			return nil, errors.New("at least one identifier is required")
+		}
+	}
+
+	if len(identifiers) > maxUserLookupBatch {
+		return nil, fmt.Errorf("too many identifiers: %d. At most %d users can be looked up at once", len(identifiers), maxUserLookupBatch)
+	}
+	return identifiers, nil
+}
+
+// handleUserLookup reports whether emails or user IDs resolve to Nobl9 users, so forms can validate them inline
+func handleUserLookup(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Only allow GET and POST requests
+	if request.HTTPMethod != "GET" && request.HTTPMethod != "POST" {
+		return respondLambdaWithStatus(http.StatusMethodNotAllowed, false, "Method not allowed")
+	}
+
+	identifiers, err := userLookupIdentifiersFromRequest(request)
+	if err != nil {
+		slog.InfoContext(ctx, "Invalid user lookup request", "error", err)
+		return respondLambdaWithStatus(http.StatusBadRequest, false, capitalizeMessage(err.Error()))
+	}
+
+	slog.InfoContext(ctx, "Processing user lookup", "identifiers", len(identifiers))
+
+	// Only callers granted lookupUsers may look users up
+	if err := authorize(ctx, authzRequest{Lookup: true}); err != nil {
+		return respondLambdaError(http.StatusForbidden, codeForbidden, err.Error(), nil)
+	}
+
+	// Create a context with timeout for all SDK operations
+	sdkCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	client, err := getNobl9Client(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to initialize Nobl9 client", "error", err)
+		return respondLambdaWithStatus(http.StatusInternalServerError, false, err.Error())
+	}
+
+	results, err := lookupUsers(sdkCtx, userLookups, client.Users().V2().GetUser, identifiers, userLookupConcurrencyFromEnv())
+	if err != nil {
+		slog.ErrorContext(ctx, "User lookup failed", "error", err)
+		return respondNobl9Error(err, "Failed to look up users in Nobl9")
+	}
+
+	resolved := 0
+	for i, result := range results {
+		if result.Resolved {
+			resolved++
+		} else if result.Code == codeUserNotFound {
+			results[i].Suggestions = suggestionsFor(sdkCtx, client, result.Identifier)
+		}
+	}
+
+	return respondLambdaJSON(http.StatusOK, UserLookupResponse{
+		Response: Response{
+			Success: true,
+			Message: fmt.Sprintf("Resolved %d of %d users", resolved, len(results)),
+		},
+		Results: results,
+	})
+}
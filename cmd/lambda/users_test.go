@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	usersV2 "github.com/nobl9/nobl9-go/sdk/endpoints/users/v2"
+)
+
+func TestLookupUsers(t *testing.T) {
+	users := &fakeUsers{users: map[string]string{
+		"alice@example.com": "00u1abcdef",
+		"00u2abcdef":        "00u2abcdef",
+		"00u3":              "00u3abcdef", // Phrase search matching a different user
+	}}
+	identifiers := []string{"alice@example.com", "missing@example.com", "bad@", "00u2abcdef", "00u3", "alice@example.com"}
+
+	results, err := lookupUsers(context.Background(), newUserLookupCache(time.Minute), users.GetUser, identifiers, 4)
+	if err != nil {
+		t.Fatalf("lookupUsers() error = %v", err)
+	}
+
+	expected := []UserLookupResult{
+		{Identifier: "alice@example.com", Resolved: true, UserID: "00u1abcdef", DisplayName: "alice@example.com", Email: "alice@example.com"},
+		{Identifier: "missing@example.com", Code: codeUserNotFound},
+		{Identifier: "bad@", Code: codeInvalidEmail},
+		{Identifier: "00u2abcdef", Resolved: true, UserID: "00u2abcdef", DisplayName: "00u2abcdef", Email: "00u2abcdef"},
+		{Identifier: "00u3", Code: codeUserNotFound},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("lookupUsers() = %+v, want %d results", results, len(expected))
+	}
+	for index, result := range results {
+		want := expected[index]
+		if result.Identifier != want.Identifier || result.Resolved != want.Resolved || result.UserID != want.UserID || result.Code != want.Code {
+			t.Errorf("lookupUsers() result %d = %+v, want %+v", index, result, want)
+		}
+	}
+	if users.calls["bad@"] != 0 {
+		t.Errorf("lookupUsers() looked up a malformed email")
+	}
+
+	// Upstream failures fail the whole lookup instead of reporting users as missing
+	if _, err := lookupUsers(context.Background(), newUserLookupCache(time.Minute), users.GetUser, []string{"alice@example.com", "error@example.com"}, 4); err == nil {
+		t.Errorf("lookupUsers() should fail when Nobl9 is unavailable")
+	} else if class := classifyNobl9Error(err); class.Code != codeNobl9Unavailable {
+		t.Errorf("lookupUsers() error class = %+v, want %s", class, codeNobl9Unavailable)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		user     usersV2.User
+		expected string
+	}{
+		{usersV2.User{FirstName: "Alice", LastName: "Smith", Email: "alice@example.com"}, "Alice Smith"},
+		{usersV2.User{FirstName: "Alice", Email: "alice@example.com"}, "Alice"},
+		{usersV2.User{Email: "alice@example.com"}, "alice@example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := displayName(&tt.user); got != tt.expected {
+			t.Errorf("displayName(%+v) = %q, want %q", tt.user, got, tt.expected)
+		}
+	}
+}
+
+func TestUserLookupIdentifiersFromRequest(t *testing.T) {
+	tooMany := `{"identifiers": [` + strings.TrimSuffix(strings.Repeat(`"a@example.com",`, maxUserLookupBatch+1), ",") + `]}`
+
+	tests := []struct {
+		name        string
+		request     events.APIGatewayProxyRequest
+		expected    []string
+		expectError bool
+	}{
+		{
+			name:     "single email",
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: map[string]string{"email": "alice@example.com"}},
+			expected: []string{"alice@example.com"},
+		},
+		{
+			name: "repeated and comma-separated emails",
+			request: events.APIGatewayProxyRequest{HTTPMethod: "GET", MultiValueQueryStringParameters: map[string][]string{
+				"email": {"alice@example.com, bob@example.com", "carol@example.com"},
+			}},
+			expected: []string{"alice@example.com", "bob@example.com", "carol@example.com"},
+		},
+		{
+			name:     "batch body",
+			request:  events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: `{"identifiers": ["alice@example.com", " ", "00u1abcdef"]}`},
+			expected: []string{"alice@example.com", "00u1abcdef"},
+		},
+		{name: "missing email", request: events.APIGatewayProxyRequest{HTTPMethod: "GET"}, expectError: true},
+		{name: "invalid body", request: events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: `["alice@example.com"]`}, expectError: true},
+		{name: "empty batch", request: events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: `{"identifiers": []}`}, expectError: true},
+		{name: "too many", request: events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: tooMany}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		identifiers, err := userLookupIdentifiersFromRequest(tt.request)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("userLookupIdentifiersFromRequest(%s) should fail, got %v", tt.name, identifiers)
+			}
+			continue
+		}
+		if err != nil || strings.Join(identifiers, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("userLookupIdentifiersFromRequest(%s) = %v, %v, want %v", tt.name, identifiers, err, tt.expected)
+		}
+	}
+}
+
+func TestHandleUserLookup(t *testing.T) {
+	// Without credentials configured, lookups fail before reaching Nobl9
+	t.Setenv("NOBL9_CLIENT_ID_PARAM_NAME", "")
+	t.Setenv("NOBL9_CLIENT_SECRET_PARAM_NAME", "")
+
+	tests := []struct {
+		name           string
+		request        events.APIGatewayProxyRequest
+		expectedStatus int
+	}{
+		{"wrong method", events.APIGatewayProxyRequest{HTTPMethod: "DELETE"}, http.StatusMethodNotAllowed},
+		{"missing email", events.APIGatewayProxyRequest{HTTPMethod: "GET"}, http.StatusBadRequest},
+		{"no credentials", events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: map[string]string{"email": "alice@example.com"}}, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		response, err := handleUserLookup(context.Background(), tt.request)
+		if err != nil {
+			t.Fatalf("handleUserLookup(%s) error = %v", tt.name, err)
+		}
+		var resp Response
+		json.Unmarshal([]byte(response.Body), &resp)
+		if response.StatusCode != tt.expectedStatus || resp.Success {
+			t.Errorf("handleUserLookup(%s) = %d %+v, want %d", tt.name, response.StatusCode, resp, tt.expectedStatus)
+		}
+	}
+
+	// Error strings start in lower case, response messages do not
+	response, _ := handleUserLookup(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	var resp Response
+	json.Unmarshal([]byte(response.Body), &resp)
+	if resp.Message != "The 'email' query parameter is required" {
+		t.Errorf("handleUserLookup() message = %q, want it capitalized", resp.Message)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Machine-readable validation error codes returned in FieldError.Code
+const (
+	codeRequired          = "required"
+	codeTooShort          = "too_short"
+	codeTooLong           = "too_long"
+	codeInvalidCharacters = "invalid_characters"
+	codeInvalidFormat     = "invalid_format"
+	codeInvalidRole       = "invalid_role"
+	codeInvalidEmail      = "invalid_email"
+	codeInvalidUserID     = "invalid_user_id"
+)
+
+// FieldError describes a single validation problem in a request
+type FieldError struct {
+	Field       string   `json:"field"`                 // Path of the offending field, e.g. userGroups[1].userIds[0]
+	Code        string   `json:"code"`                  // Machine-readable error code
+	Message     string   `json:"message"`               // Human-readable description of the problem
+	Value       string   `json:"value,omitempty"`       // Offending value, if any
+	Suggestions []string `json:"suggestions,omitempty"` // Known users an unresolved email may have been meant to be
+}
+
+// validationError is an error carrying a machine-readable validation code
+type validationError struct {
+	code    string
+	message string
+}
+
+// newValidationError creates a validation error with the given code and message
+func newValidationError(code, message string) error {
+	return &validationError{code: code, message: message}
+}
+
+// Error returns the human-readable validation message
+func (e *validationError) Error() string {
+	return e.message
+}
+
+// validationErrorCode extracts the validation code from an error, defaulting to invalid_format
+func validationErrorCode(err error) string {
+	var vErr *validationError
+	if errors.As(err, &vErr) {
+		return vErr.code
+	}
+	return codeInvalidFormat
+}
+
+// validateCreateProjectRequest validates the whole create project request and returns every problem found
+func validateCreateProjectRequest(req CreateProjectRequest) []FieldError {
+	var fieldErrors []FieldError
+
+	if err := validateProjectName(req.AppID); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   "appID",
+			Code:    validationErrorCode(err),
+			Message: err.Error(),
+			Value:   req.AppID,
+		})
+	}
+
+	fieldErrors = append(fieldErrors, validateUserGroups(req.UserGroups)...)
+	return append(fieldErrors, validateDuplicateUsersOption(req.DuplicateUsers)...)
+}
+
+// validateUserGroups validates all roles and user identifiers in the given user groups
+// and returns every problem found, with group and position indexes in the field path
+func validateUserGroups(userGroups []UserGroup) []FieldError {
+	if len(userGroups) == 0 {
+		return []FieldError{{
+			Field:   "userGroups",
+			Code:    codeRequired,
+			Message: "At least one user group is required",
+		}}
+	}
+
+	var fieldErrors []FieldError
+	for groupIndex, group := range userGroups {
+		if !validRoles[group.Role] {
+			fieldErrors = append(fieldErrors, FieldError{
+				Field:   fmt.Sprintf("userGroups[%d].role", groupIndex),
+				Code:    codeInvalidRole,
+				Message: fmt.Sprintf("Invalid role '%s' in group %d. Must be one of: %s", group.Role, groupIndex, getValidRoles()),
+				Value:   group.Role,
+			})
+		}
+
+		// Validate all user identifiers in this group, keeping their position in the list
+		for _, entry := range userIdentifierEntries(group.UserIDs) {
+			if err := validateUserIdentifier(entry.Identifier); err != nil {
+				fieldErrors = append(fieldErrors, FieldError{
+					Field:   userIdentifierField(groupIndex, entry.Position),
+					Code:    validationErrorCode(err),
+					Message: err.Error(),
+					Value:   entry.Identifier,
+				})
+			}
+		}
+	}
+	return fieldErrors
+}
+
+// validateUserIdentifier checks that an identifier is a well-formed email or a plausible user ID
+func validateUserIdentifier(userIdentifier string) error {
+	switch {
+	case strings.Contains(userIdentifier, ","):
+		return newValidationError(codeInvalidFormat, fmt.Sprintf("Invalid user identifier: '%s' (must be a single email or user ID)", userIdentifier))
+	case looksLikeEmail(userIdentifier):
+		if !validateEmail(userIdentifier) {
+			return newValidationError(codeInvalidEmail, fmt.Sprintf("Invalid email format: '%s'. Email addresses must contain @ symbol and be properly formatted (e.g., user@domain.com).", userIdentifier))
+		}
+	case len(userIdentifier) < 2:
+		return newValidationError(codeInvalidUserID, fmt.Sprintf("Invalid user ID: '%s' (too short)", userIdentifier))
+	}
+	return nil
+}
+
+// validationSummary builds the backward-compatible message for a list of validation errors.
+// A single error keeps its original message; multiple errors are listed as bullets.
+func validationSummary(fieldErrors []FieldError) string {
+	if len(fieldErrors) == 1 {
+		return fieldErrors[0].Message
+	}
+
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		messages = append(messages, fieldError.Message)
+	}
+	return fmt.Sprintf("Request validation failed with %d errors:\n• %s", len(fieldErrors), strings.Join(messages, "\n• "))
+}
+
+// respondValidationErrors sends a 400 response listing every validation error
+func respondValidationErrors(fieldErrors []FieldError) (events.APIGatewayProxyResponse, error) {
+	message := validationSummary(fieldErrors)
+	return respondLambdaJSON(http.StatusBadRequest, Response{
+		Success: false,
+		Code:    codeValidationFailed,
+		Message: message,
+		Errors:  fieldErrors,
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestValidationErrorCode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", codeRequired},
+		{"ab", codeTooShort},
+		{strings.Repeat("a", 64), codeTooLong},
+		{"Invalid-Project", codeInvalidCharacters},
+		{"-project", codeInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		result := validationErrorCode(validateProjectName(tt.input))
+		if result != tt.expected {
+			t.Errorf("validationErrorCode(validateProjectName(%q)) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestValidateUserGroups(t *testing.T) {
+	tests := []struct {
+		groups         []UserGroup
+		expectedFields []string
+	}{
+		{[]UserGroup{{UserIDs: "user@example.com", Role: "project-owner"}}, nil},
+		{[]UserGroup{{UserIDs: "user@example.com, user-id", Role: "project-viewer"}}, nil},
+		{nil, []string{"userGroups"}},
+		{[]UserGroup{{UserIDs: "user@example.com", Role: "invalid-role"}}, []string{"userGroups[0].role"}},
+		{[]UserGroup{{UserIDs: "invalid-email@", Role: "project-owner"}}, []string{"userGroups[0].userIds[0]"}},
+		{[]UserGroup{{UserIDs: "user@example.com,,a", Role: "project-owner"}}, []string{"userGroups[0].userIds[2]"}},
+		{
+			[]UserGroup{
+				{UserIDs: "user@example.com", Role: "project-owner"},
+				{UserIDs: "bad@, also-bad@example", Role: "project-admin"},
+			},
+			[]string{"userGroups[1].role", "userGroups[1].userIds[0]", "userGroups[1].userIds[1]"},
+		},
+	}
+
+	for _, tt := range tests {
+		fieldErrors := validateUserGroups(tt.groups)
+		var fields []string
+		for _, fieldError := range fieldErrors {
+			fields = append(fields, fieldError.Field)
+		}
+		if !reflect.DeepEqual(fields, tt.expectedFields) {
+			t.Errorf("validateUserGroups(%+v) fields = %v, want %v", tt.groups, fields, tt.expectedFields)
+		}
+	}
+
+	// User identifiers are reported exactly as the single-user endpoints report them
+	fieldErrors := validateUserGroups([]UserGroup{{UserIDs: "bad@, x", Role: "project-viewer"}})
+	for _, fieldError := range fieldErrors {
+		err := validateUserIdentifier(fieldError.Value)
+		if err == nil || fieldError.Code != validationErrorCode(err) || fieldError.Message != err.Error() {
+			t.Errorf("validateUserGroups() error %+v, want it to match validateUserIdentifier(%q) = %v", fieldError, fieldError.Value, err)
+		}
+	}
+	if len(fieldErrors) != 2 {
+		t.Errorf("validateUserGroups() returned %d errors, want 2: %+v", len(fieldErrors), fieldErrors)
+	}
+}
+
+func TestValidateCreateProjectRequest(t *testing.T) {
+	req := CreateProjectRequest{
+		AppID: "Bad_Project",
+		UserGroups: []UserGroup{
+			{UserIDs: "user@example.com,invalid-email@", Role: "project-owner"},
+			{UserIDs: "x", Role: "invalid-role"},
+		},
+	}
+
+	fieldErrors := validateCreateProjectRequest(req)
+
+	expected := []FieldError{
+		{Field: "appID", Code: codeInvalidCharacters, Value: "Bad_Project"},
+		{Field: "userGroups[0].userIds[1]", Code: codeInvalidEmail, Value: "invalid-email@"},
+		{Field: "userGroups[1].role", Code: codeInvalidRole, Value: "invalid-role"},
+		{Field: "userGroups[1].userIds[0]", Code: codeInvalidUserID, Value: "x"},
+	}
+
+	if len(fieldErrors) != len(expected) {
+		t.Fatalf("validateCreateProjectRequest() returned %d errors, want %d: %+v", len(fieldErrors), len(expected), fieldErrors)
+	}
+
+	for i, fieldError := range fieldErrors {
+		if fieldError.Field != expected[i].Field || fieldError.Code != expected[i].Code || fieldError.Value != expected[i].Value {
+			t.Errorf("validateCreateProjectRequest() error %d = %+v, want %+v", i, fieldError, expected[i])
+		}
+		if fieldError.Message == "" {
+			t.Errorf("validateCreateProjectRequest() error %d has no message", i)
+		}
+	}
+}
+
+func TestValidationSummary(t *testing.T) {
+	single := []FieldError{{Field: "appID", Code: codeRequired, Message: "project name cannot be empty"}}
+	if result := validationSummary(single); result != "project name cannot be empty" {
+		t.Errorf("validationSummary() = %q, want original message", result)
+	}
+
+	multiple := append(single, FieldError{Field: "userGroups", Code: codeRequired, Message: "At least one user group is required"})
+	result := validationSummary(multiple)
+	if !strings.HasPrefix(result, "Request validation failed with 2 errors") ||
+		!strings.Contains(result, "project name cannot be empty") ||
+		!strings.Contains(result, "At least one user group is required") {
+		t.Errorf("validationSummary() = %q", result)
+	}
+}
+
+func TestHandleCreateProjectReturnsAllErrors(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/api/create-project",
+		Body:       `{"appID": "ab", "userGroups": [{"userIds": "invalid-email@", "role": "invalid-role"}]}`,
+	}
+
+	response, err := handleCreateProject(context.Background(), request)
+	if err != nil {
+		t.Errorf("handleCreateProject() error = %v", err)
+	}
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("handleCreateProject() status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(response.Body), &resp); err != nil {
+		t.Errorf("Failed to parse response: %v", err)
+	}
+
+	if resp.Success || resp.Message == "" {
+		t.Errorf("Response = %+v, want failure with message", resp)
+	}
+
+	if len(resp.Errors) != 3 {
+		t.Errorf("Response errors = %+v, want 3 errors", resp.Errors)
+	}
+}
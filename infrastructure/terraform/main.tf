@@ -186,6 +186,23 @@ resource "aws_ssm_parameter" "nobl9_client_secret" {
   }
 }
 
+# DynamoDB table for Idempotency-Key records, shared by all Lambda instances
+resource "aws_dynamodb_table" "idempotency" {
+  name         = "${var.project_name}-idempotency"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "idempotencyKey"
+
+  attribute {
+    name = "idempotencyKey"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+}
+
 # Lambda function code archive
 data "archive_file" "lambda_function" {
   type        = "zip"
@@ -226,7 +243,7 @@ resource "aws_iam_role_policy" "lambda_execution_policy" {
 
   policy = jsonencode({
     Version = "2012-10-17"
-    Statement = [
+    Statement = concat([
       {
         Effect = "Allow"
         Action = [
@@ -257,8 +274,29 @@ resource "aws_iam_role_policy" "lambda_execution_policy" {
             "kms:ViaService" = "ssm.${var.aws_region}.amazonaws.com"
           }
         }
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "dynamodb:GetItem",
+          "dynamodb:PutItem",
+          "dynamodb:DeleteItem"
+        ]
+        Resource = aws_dynamodb_table.idempotency.arn
       }
-    ]
+    ], var.authz_policy_param_name == "" ? [] : [
+      {
+        Effect   = "Allow"
+        Action   = ["ssm:GetParameter"]
+        Resource = "arn:aws:ssm:${var.aws_region}:${data.aws_caller_identity.current.account_id}:parameter${var.authz_policy_param_name}"
+      }
+    ], var.nobl9_ca_bundle_param_name == "" ? [] : [
+      {
+        Effect   = "Allow"
+        Action   = ["ssm:GetParameter"]
+        Resource = "arn:aws:ssm:${var.aws_region}:${data.aws_caller_identity.current.account_id}:parameter${var.nobl9_ca_bundle_param_name}"
+      }
+    ])
   })
 }
 
@@ -289,6 +327,9 @@ resource "aws_lambda_function" "nobl9_wizard" {
       NOBL9_CLIENT_ID_PARAM_NAME     = aws_ssm_parameter.nobl9_client_id.name
       NOBL9_CLIENT_SECRET_PARAM_NAME = aws_ssm_parameter.nobl9_client_secret.name
       NOBL9_SKIP_TLS_VERIFY          = var.nobl9_skip_tls_verify
+      IDEMPOTENCY_TABLE_NAME         = aws_dynamodb_table.idempotency.name
+      AUTHZ_POLICY_PARAM_NAME        = var.authz_policy_param_name
+      NOBL9_CA_BUNDLE_PARAM_NAME     = var.nobl9_ca_bundle_param_name
     }
   }
 
@@ -418,6 +459,246 @@ resource "aws_api_gateway_integration_response" "create_project_options" {
   }
 }
 
+# API Gateway Resource for /api/projects
+resource "aws_api_gateway_resource" "projects" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  parent_id   = aws_api_gateway_resource.api.id
+  path_part   = "projects"
+}
+
+# API Gateway Resource for /api/projects/{name}
+resource "aws_api_gateway_resource" "project" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  parent_id   = aws_api_gateway_resource.projects.id
+  path_part   = "{name}"
+}
+
+# API Gateway Method for GET /api/projects/{name}
+resource "aws_api_gateway_method" "project_get" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.project.id
+  http_method   = "GET"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for GET /api/projects/{name}
+resource "aws_api_gateway_integration" "project_get" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.project.id
+  http_method = aws_api_gateway_method.project_get.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Method for OPTIONS /api/projects/{name} (CORS, answered by the function)
+resource "aws_api_gateway_method" "project_options" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.project.id
+  http_method   = "OPTIONS"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for OPTIONS /api/projects/{name}
+resource "aws_api_gateway_integration" "project_options" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.project.id
+  http_method = aws_api_gateway_method.project_options.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Resource for /api/projects/{name}/members
+resource "aws_api_gateway_resource" "project_members" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  parent_id   = aws_api_gateway_resource.project.id
+  path_part   = "members"
+}
+
+# API Gateway Method for POST /api/projects/{name}/members
+resource "aws_api_gateway_method" "project_members_post" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.project_members.id
+  http_method   = "POST"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for POST /api/projects/{name}/members
+resource "aws_api_gateway_integration" "project_members_post" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.project_members.id
+  http_method = aws_api_gateway_method.project_members_post.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Method for OPTIONS /api/projects/{name}/members (CORS, answered by the function)
+resource "aws_api_gateway_method" "project_members_options" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.project_members.id
+  http_method   = "OPTIONS"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for OPTIONS /api/projects/{name}/members
+resource "aws_api_gateway_integration" "project_members_options" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.project_members.id
+  http_method = aws_api_gateway_method.project_members_options.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Resource for /api/projects/{name}/members/{user}
+resource "aws_api_gateway_resource" "project_member" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  parent_id   = aws_api_gateway_resource.project_members.id
+  path_part   = "{user}"
+}
+
+# API Gateway Method for DELETE /api/projects/{name}/members/{user}
+resource "aws_api_gateway_method" "project_member_delete" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.project_member.id
+  http_method   = "DELETE"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for DELETE /api/projects/{name}/members/{user}
+resource "aws_api_gateway_integration" "project_member_delete" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.project_member.id
+  http_method = aws_api_gateway_method.project_member_delete.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Method for OPTIONS /api/projects/{name}/members/{user} (CORS, answered by the function)
+resource "aws_api_gateway_method" "project_member_options" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.project_member.id
+  http_method   = "OPTIONS"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for OPTIONS /api/projects/{name}/members/{user}
+resource "aws_api_gateway_integration" "project_member_options" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.project_member.id
+  http_method = aws_api_gateway_method.project_member_options.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Resource for /api/{proxy+}. Path parts cannot contain ':', so custom-method paths
+# such as /api/projects:batch reach the function, which routes them, through this greedy resource
+resource "aws_api_gateway_resource" "api_proxy" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  parent_id   = aws_api_gateway_resource.api.id
+  path_part   = "{proxy+}"
+}
+
+# API Gateway Method for POST /api/{proxy+}
+resource "aws_api_gateway_method" "api_proxy_post" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.api_proxy.id
+  http_method   = "POST"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for POST /api/{proxy+}
+resource "aws_api_gateway_integration" "api_proxy_post" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.api_proxy.id
+  http_method = aws_api_gateway_method.api_proxy_post.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Method for OPTIONS /api/{proxy+} (CORS, answered by the function)
+resource "aws_api_gateway_method" "api_proxy_options" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.api_proxy.id
+  http_method   = "OPTIONS"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for OPTIONS /api/{proxy+}
+resource "aws_api_gateway_integration" "api_proxy_options" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.api_proxy.id
+  http_method = aws_api_gateway_method.api_proxy_options.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Resource for /api/users
+resource "aws_api_gateway_resource" "users" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  parent_id   = aws_api_gateway_resource.api.id
+  path_part   = "users"
+}
+
+# API Gateway Resource for /api/users/lookup
+resource "aws_api_gateway_resource" "user_lookup" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  parent_id   = aws_api_gateway_resource.users.id
+  path_part   = "lookup"
+}
+
+# API Gateway Method for GET /api/users/lookup
+resource "aws_api_gateway_method" "user_lookup_get" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.user_lookup.id
+  http_method   = "GET"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for GET /api/users/lookup
+resource "aws_api_gateway_integration" "user_lookup_get" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.user_lookup.id
+  http_method = aws_api_gateway_method.user_lookup_get.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
+# API Gateway Method for OPTIONS /api/users/lookup (CORS, answered by the function)
+resource "aws_api_gateway_method" "user_lookup_options" {
+  rest_api_id   = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id   = aws_api_gateway_resource.user_lookup.id
+  http_method   = "OPTIONS"
+  authorization = "NONE"
+}
+
+# API Gateway Integration for OPTIONS /api/users/lookup
+resource "aws_api_gateway_integration" "user_lookup_options" {
+  rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
+  resource_id = aws_api_gateway_resource.user_lookup.id
+  http_method = aws_api_gateway_method.user_lookup_options.http_method
+
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.nobl9_wizard.invoke_arn
+}
+
 # Lambda permission for API Gateway
 resource "aws_lambda_permission" "api_gateway" {
   statement_id  = "AllowExecutionFromAPIGateway"
@@ -433,12 +714,40 @@ resource "aws_api_gateway_deployment" "nobl9_wizard" {
     aws_api_gateway_integration.create_project_post,
     aws_api_gateway_integration.create_project_options,
     aws_api_gateway_integration_response.create_project_post,
-    aws_api_gateway_integration_response.create_project_options
+    aws_api_gateway_integration_response.create_project_options,
+    aws_api_gateway_integration.project_get,
+    aws_api_gateway_integration.project_options,
+    aws_api_gateway_integration.project_members_post,
+    aws_api_gateway_integration.project_members_options,
+    aws_api_gateway_integration.project_member_delete,
+    aws_api_gateway_integration.project_member_options,
+    aws_api_gateway_integration.api_proxy_post,
+    aws_api_gateway_integration.api_proxy_options,
+    aws_api_gateway_integration.user_lookup_get,
+    aws_api_gateway_integration.user_lookup_options
   ]
 
   rest_api_id = aws_api_gateway_rest_api.nobl9_wizard.id
   stage_name  = var.environment
 
+  # Redeploy the stage whenever an endpoint is added or changed
+  triggers = {
+    redeployment = sha1(jsonencode([
+      aws_api_gateway_integration.create_project_post.id,
+      aws_api_gateway_integration.create_project_options.id,
+      aws_api_gateway_integration.project_get.id,
+      aws_api_gateway_integration.project_options.id,
+      aws_api_gateway_integration.project_members_post.id,
+      aws_api_gateway_integration.project_members_options.id,
+      aws_api_gateway_integration.project_member_delete.id,
+      aws_api_gateway_integration.project_member_options.id,
+      aws_api_gateway_integration.api_proxy_post.id,
+      aws_api_gateway_integration.api_proxy_options.id,
+      aws_api_gateway_integration.user_lookup_get.id,
+      aws_api_gateway_integration.user_lookup_options.id,
+    ]))
+  }
+
   lifecycle {
     create_before_destroy = true
   }
@@ -86,6 +86,11 @@ output "parameter_store_client_secret_name" {
   value       = aws_ssm_parameter.nobl9_client_secret.name
 }
 
+output "idempotency_table_name" {
+  description = "Name of the DynamoDB table holding Idempotency-Key records"
+  value       = aws_dynamodb_table.idempotency.name
+}
+
 output "cloudwatch_log_group_name" {
   description = "Name of the CloudWatch log group for Lambda function"
   value       = aws_cloudwatch_log_group.lambda_function.name
@@ -43,6 +43,18 @@ variable "nobl9_skip_tls_verify" {
   default     = "false"
 }
 
+variable "nobl9_ca_bundle_param_name" {
+  description = "Parameter Store name, starting with /, of a PEM CA bundle to trust for Nobl9 requests; leave empty to disable"
+  type        = string
+  default     = ""
+}
+
+variable "authz_policy_param_name" {
+  description = "Parameter Store name, starting with /, of the authorization policy; leave empty to disable"
+  type        = string
+  default     = ""
+}
+
 variable "lambda_timeout" {
   description = "Lambda function timeout in seconds"
   type        = number